@@ -0,0 +1,344 @@
+package config
+
+/*
+
+将配置数据解析到带tag的结构体中，用于取代对RawData的手动类型断言。
+
+支持的tag：
+
+	config:"<key>[,required]"	配置项名称。为空时使用字段名的小写形式，为'-'时忽略该字段。
+								required表示该配置项必须提供。
+	default:"<value>"			配置项未提供时的默认值，按字段类型解析。
+
+支持的字段类型：string, bool, int*, uint*, float*, time.Duration, slice, map[string]T, struct, 以及它们的指针。
+其中：
+	time.Duration	支持"30s"格式的字符串，或表示秒数的数字。
+	slice			支持列表，或以','分隔的字符串。
+	string			数字、bool类型的配置值会被转为字符串，如纯数字的密码。
+	*struct			配置未提供时保持为nil，其中的default、required不生效；已初始化的指针则与struct相同。
+
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 解析失败时返回的错误，汇总了所有缺失、类型错误的配置项。
+type BindError struct {
+	Errors []string
+}
+
+func (e *BindError) Error() string {
+	return "invalid config. " + strings.Join(e.Errors, "; ")
+}
+
+type binder struct {
+	errors []string
+}
+
+func (b *binder) addError(path string, format string, args ...interface{}) {
+	b.errors = append(b.errors, fmt.Sprintf("'%s': ", path)+fmt.Sprintf(format, args...))
+}
+
+// 将section对应的配置解析到target中，target必须是结构体指针。
+// section以'.'表示嵌套，如`nebula_info`，为空表示顶层配置。
+// section不存在时视为空字典，仍会填充默认值、检查required。
+func Bind(section string, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		panic("config.Bind(): target must be a non-nil pointer to struct. Please check your code!")
+	}
+
+	var data interface{} = Raw()
+	if section != "" {
+		val, ok := Get(section)
+		if !ok {
+			val = map[interface{}]interface{}{}
+		}
+		data = val
+	}
+
+	b := &binder{}
+	b.decode(section, data, rv.Elem())
+	if len(b.errors) > 0 {
+		return &BindError{Errors: b.errors}
+	}
+	return nil
+}
+
+// 按'.'分隔的路径获取配置值。
+func Get(key string) (interface{}, bool) {
+	return getFrom(Raw(), key)
+}
+
+func getFrom(data map[string]interface{}, key string) (interface{}, bool) {
+	path := strings.Split(key, ".")
+	val, ok := data[path[0]]
+	for _, k := range path[1:] {
+		if !ok {
+			return nil, false
+		}
+		node, isMap := val.(map[interface{}]interface{})
+		if !isMap {
+			return nil, false
+		}
+		val, ok = node[k]
+	}
+	return val, ok
+}
+
+func joinPath(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// 将字典统一转为map[string]interface{}。
+func toStringMap(val interface{}) (map[string]interface{}, bool) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			res[fmt.Sprintf("%v", key)] = item
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+func (b *binder) decode(path string, val interface{}, rv reflect.Value) {
+	// time.Duration底层为int64，需要优先处理
+	if rv.Type() == reflect.TypeOf(time.Duration(0)) {
+		b.decodeDuration(path, val, rv)
+		return
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		b.decode(path, val, rv.Elem())
+	case reflect.Struct:
+		b.decodeStruct(path, val, rv)
+	case reflect.String:
+		switch v := val.(type) {
+		case string:
+			rv.SetString(v)
+		case int, int64, float64, bool:
+			rv.SetString(fmt.Sprintf("%v", v))
+		default:
+			b.addError(path, "expect a string, got %T", val)
+		}
+	case reflect.Bool:
+		switch v := val.(type) {
+		case bool:
+			rv.SetBool(v)
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				b.addError(path, "expect a bool, got '%s'", v)
+				return
+			}
+			rv.SetBool(parsed)
+		default:
+			b.addError(path, "expect a bool, got %T", val)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, ok := toInt64(val)
+		if !ok || rv.OverflowInt(num) {
+			b.addError(path, "expect an int, got %T '%v'", val, val)
+			return
+		}
+		rv.SetInt(num)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		num, ok := toInt64(val)
+		if !ok || num < 0 || rv.OverflowUint(uint64(num)) {
+			b.addError(path, "expect an unsigned int, got %T '%v'", val, val)
+			return
+		}
+		rv.SetUint(uint64(num))
+	case reflect.Float32, reflect.Float64:
+		num, ok := toFloat64(val)
+		if !ok {
+			b.addError(path, "expect a float, got %T '%v'", val, val)
+			return
+		}
+		rv.SetFloat(num)
+	case reflect.Slice:
+		b.decodeSlice(path, val, rv)
+	case reflect.Map:
+		b.decodeMap(path, val, rv)
+	case reflect.Interface:
+		if val == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+			return
+		}
+		rv.Set(reflect.ValueOf(val))
+	default:
+		panic(fmt.Sprintf("config.Bind(): unsupported field type '%s' for '%s'. Please check your code!", rv.Type(), path))
+	}
+}
+
+func (b *binder) decodeStruct(path string, val interface{}, rv reflect.Value) {
+	data, ok := toStringMap(val)
+	if !ok {
+		b.addError(path, "expect a dict, got %T", val)
+		return
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue // 非导出字段
+		}
+
+		tag := field.Tag.Get("config")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		key := opts[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		required := false
+		for _, opt := range opts[1:] {
+			if opt == "required" {
+				required = true
+			}
+		}
+
+		fieldPath := joinPath(path, key)
+		fieldVal, ok := data[key]
+		if ok && fieldVal != nil {
+			b.decode(fieldPath, fieldVal, rv.Field(i))
+			continue
+		}
+
+		// 配置项未提供
+		if required {
+			b.addError(fieldPath, "must be provided")
+			continue
+		}
+		if defVal, ok := field.Tag.Lookup("default"); ok {
+			b.decode(fieldPath, defVal, rv.Field(i))
+			continue
+		}
+		// 嵌套结构体也需要填充默认值、检查required。为nil的结构体指针表示该配置未提供，保持为nil
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			b.decodeStruct(fieldPath, map[string]interface{}{}, fv)
+		}
+	}
+}
+
+func (b *binder) decodeSlice(path string, val interface{}, rv reflect.Value) {
+	var items []interface{}
+	switch v := val.(type) {
+	case []interface{}:
+		items = v
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	default:
+		b.addError(path, "expect a list, got %T", val)
+		return
+	}
+
+	slice := reflect.MakeSlice(rv.Type(), len(items), len(items))
+	for i, item := range items {
+		b.decode(fmt.Sprintf("%s[%d]", path, i), item, slice.Index(i))
+	}
+	rv.Set(slice)
+}
+
+func (b *binder) decodeMap(path string, val interface{}, rv reflect.Value) {
+	if rv.Type().Key().Kind() != reflect.String {
+		panic(fmt.Sprintf("config.Bind(): map key must be a string for '%s'. Please check your code!", path))
+	}
+	data, ok := toStringMap(val)
+	if !ok {
+		b.addError(path, "expect a dict, got %T", val)
+		return
+	}
+
+	res := reflect.MakeMapWithSize(rv.Type(), len(data))
+	for key, item := range data {
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		b.decode(joinPath(path, key), item, elem)
+		res.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+	}
+	rv.Set(res)
+}
+
+func (b *binder) decodeDuration(path string, val interface{}, rv reflect.Value) {
+	switch v := val.(type) {
+	case string:
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			rv.SetInt(int64(d))
+			return
+		}
+		// 纯数字的字符串，按秒处理
+		if sec, ok := toFloat64(v); ok {
+			rv.SetInt(int64(sec * float64(time.Second)))
+			return
+		}
+		b.addError(path, "expect a duration like '30s', got '%s'", v)
+	default:
+		sec, ok := toFloat64(v)
+		if !ok {
+			b.addError(path, "expect a duration, got %T", val)
+			return
+		}
+		rv.SetInt(int64(sec * float64(time.Second)))
+	}
+}
+
+func toInt64(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), v <= 1<<63-1
+	case float64:
+		return int64(v), v == float64(int64(v))
+	case string:
+		num, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return num, err == nil
+	}
+	return 0, false
+}
+
+func toFloat64(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		num, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return num, err == nil
+	}
+	return 0, false
+}
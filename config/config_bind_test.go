@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 写入临时配置文件并加载，测试结束时恢复原有配置
+func useConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldFiles, oldPrefix := ConfigFiles, EnvPrefix
+	ConfigFiles, EnvPrefix = []string{path}, ""
+	t.Cleanup(func() {
+		ConfigFiles, EnvPrefix = oldFiles, oldPrefix
+		Parse()
+	})
+	if err := Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type testServerConf struct {
+	Host    string        `config:"host,required"`
+	Port    int           `config:"port" default:"8080"`
+	Timeout time.Duration `config:"timeout" default:"3s"`
+	Tags    []string      `config:"tags"`
+	Weights []float64     `config:"weights"`
+	Extra   []interface{} `config:"extra"`
+	Ignored string        `config:"-"`
+}
+
+type testPoolConf struct {
+	Size int `config:"size" default:"10"`
+}
+
+type testBindConf struct {
+	Name    string                    `config:"name"`
+	Debug   bool                      `config:"debug"`
+	Server  testServerConf            `config:"server"`
+	Pool    *testPoolConf             `config:"pool"`
+	Servers map[string]testServerConf `config:"servers"`
+}
+
+func TestBind(t *testing.T) {
+	useConfig(t, `
+name: 1234
+debug: "true"
+server:
+  host: a.local
+  timeout: 1.5
+  tags: x, y
+  weights: [1, 0.5]
+  extra: [1, ~, b]
+servers:
+  b:
+    host: b.local
+    port: "9000"
+section:
+  key: v
+`)
+
+	conf := testBindConf{}
+	if err := Bind("", &conf); err != nil {
+		t.Fatal(err)
+	}
+	want := testBindConf{
+		Name:  "1234",
+		Debug: true,
+		Server: testServerConf{
+			Host: "a.local", Port: 8080, Timeout: 1500 * time.Millisecond,
+			Tags: []string{"x", "y"}, Weights: []float64{1, 0.5}, Extra: []interface{}{1, nil, "b"},
+		},
+		Servers: map[string]testServerConf{"b": {Host: "b.local", Port: 9000, Timeout: 3 * time.Second}},
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("expect %+v, got %+v", want, conf)
+	}
+
+	// 已初始化的结构体指针同样填充默认值
+	conf = testBindConf{Pool: &testPoolConf{}}
+	if err := Bind("", &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Pool.Size != 10 {
+		t.Errorf("expect default pool size, got %+v", conf.Pool)
+	}
+
+	// 嵌套的section
+	section := struct {
+		Key string `config:"key"`
+	}{}
+	if err := Bind("section", &section); err != nil || section.Key != "v" {
+		t.Errorf("unexpected section %+v, %v", section, err)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	useConfig(t, `
+name: [a]
+server:
+  port: abc
+  timeout: soon
+servers:
+  b:
+    port: 1.5
+    tags: {a: 1}
+`)
+
+	conf := testBindConf{}
+	err := Bind("", &conf)
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("expect a BindError, got %v", err)
+	}
+	want := []string{
+		"'name': expect a string",
+		"'server.host': must be provided",
+		"'server.port': expect an int",
+		"'server.timeout': expect a duration like '30s', got 'soon'",
+		"'servers.b.host': must be provided",
+		"'servers.b.port': expect an int",
+		"'servers.b.tags': expect a list",
+	}
+	if len(bindErr.Errors) != len(want) {
+		t.Fatalf("expect %d errors, got %v", len(want), bindErr.Errors)
+	}
+	for _, str := range want {
+		if !strings.Contains(err.Error(), str) {
+			t.Errorf("expect %q in %q", str, err.Error())
+		}
+	}
+
+	// section不存在时仍检查required
+	if err := Bind("missing", &testServerConf{}); !errors.As(err, &bindErr) || len(bindErr.Errors) != 1 {
+		t.Errorf("expect a required error, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package config
+
+/*
+
+输出当前生效的配置，用于调试。
+
+每个配置项都标注了其来源：
+	default				内置默认值
+	<file path>			配置文件
+	profile:<name>		profile
+	env:<ENV_NAME>		环境变量
+	flag:-set			命令行参数
+
+敏感信息会被脱敏，详见config_secret.go。
+
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const SOURCE_DEFAULT = "default"
+
+// 命令行参数`-dump-config`
+var flagDump string
+
+// 一个配置源所设置的所有配置项
+type layer struct {
+	source string
+	paths  map[string]bool
+}
+
+func newLayer(source string, data map[string]interface{}) layer {
+	l := layer{source: source, paths: map[string]bool{}}
+	for key, val := range data {
+		collectLeafPaths(key, val, l.paths)
+	}
+	return l
+}
+
+func pathLayer(source string, path []string) layer {
+	return layer{source: source, paths: map[string]bool{strings.Join(path, "."): true}}
+}
+
+// 收集所有叶子节点的路径。非空字典继续展开，其他值(包括列表)均视为叶子节点。
+func collectLeafPaths(path string, val interface{}, paths map[string]bool) {
+	if node, ok := toStringMap(val); ok && len(node) > 0 {
+		for key, item := range node {
+			collectLeafPaths(joinPath(path, key), item, paths)
+		}
+		return
+	}
+	paths[path] = true
+}
+
+// 对最终配置的每个叶子节点，找到最后设置它的配置源。
+func resolveSources(data map[string]interface{}, layers []layer) map[string]string {
+	leaves := map[string]bool{}
+	for key, val := range data {
+		collectLeafPaths(key, val, leaves)
+	}
+
+	sources := make(map[string]string, len(leaves))
+	for path := range leaves {
+		for i := len(layers) - 1; i >= 0; i-- {
+			if layers[i].paths[path] {
+				sources[path] = layers[i].source
+				break
+			}
+		}
+	}
+	return sources
+}
+
+// 获取某个配置项的来源，key以'.'表示嵌套。未知时返回空字符串。
+func Source(key string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sourceMap[key]
+}
+
+// 获取所有配置项的来源，key为以'.'分隔的路径。
+func Sources() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	res := make(map[string]string, len(sourceMap))
+	for key, val := range sourceMap {
+		res[key] = val
+	}
+	return res
+}
+
+// 以yaml或json格式输出当前生效的配置，敏感信息已脱敏。
+//
+// yaml格式中，每个配置项以行尾注释标注来源。
+// json格式为：{"config": {...}, "sources": {"<key>": "<source>"}}
+func Dump(format string) (string, error) {
+	data := Redacted()
+	sources := Sources()
+
+	switch strings.ToLower(format) {
+	case "yaml", "yml", "":
+		var sb strings.Builder
+		if err := dumpYaml(&sb, data, sources); err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	case "json":
+		res, err := json.MarshalIndent(map[string]interface{}{
+			"config":  ToJSONable(data),
+			"sources": sources,
+		}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(res), nil
+	}
+	return "", fmt.Errorf("unsupported dump format '%s', must be 'yaml' or 'json'", format)
+}
+
+// 将配置数据中的map[interface{}]interface{}转为map[string]interface{}，以便json序列化。
+func ToJSONable(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			res[key] = ToJSONable(item)
+		}
+		return res
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			res[fmt.Sprintf("%v", key)] = ToJSONable(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = ToJSONable(item)
+		}
+		return res
+	}
+	return val
+}
+
+func dumpYaml(sb *strings.Builder, data map[string]interface{}, sources map[string]string) error {
+	return dumpYamlNode(sb, "", 0, data, sources)
+}
+
+func dumpYamlNode(sb *strings.Builder, path string, depth int, node map[string]interface{}, sources map[string]string) error {
+	keys := make([]string, 0, len(node))
+	for key := range node {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	indent := strings.Repeat("  ", depth)
+	for _, key := range keys {
+		keyPath := joinPath(path, key)
+		keyBytes, err := yaml.Marshal(key)
+		if err != nil {
+			return err
+		}
+		keyStr := strings.TrimSpace(string(keyBytes))
+
+		// 非空字典，递归输出
+		if child, ok := toStringMap(node[key]); ok && len(child) > 0 {
+			sb.WriteString(fmt.Sprintf("%s%s:\n", indent, keyStr))
+			if err := dumpYamlNode(sb, keyPath, depth+1, child, sources); err != nil {
+				return err
+			}
+			continue
+		}
+
+		valBytes, err := yaml.Marshal(node[key])
+		if err != nil {
+			return err
+		}
+		valStr := strings.TrimRight(string(valBytes), "\n")
+		comment := ""
+		if source, ok := sources[keyPath]; ok {
+			comment = "  # " + source
+		}
+
+		if strings.Contains(valStr, "\n") {
+			// 列表等多行的值，另起一行缩进输出
+			sb.WriteString(fmt.Sprintf("%s%s:%s\n", indent, keyStr, comment))
+			for _, line := range strings.Split(valStr, "\n") {
+				sb.WriteString(fmt.Sprintf("%s  %s\n", indent, line))
+			}
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s%s: %s%s\n", indent, keyStr, valStr, comment))
+	}
+	return nil
+}
+
+// 处理配置相关的命令行参数，在启动时Parse()成功之后调用一次。
+// 指定了`-dump-config`时，输出配置后退出进程。Parse()、Reload()不会调用此方法。
+func HandleFlags() {
+	if flagDump == "" {
+		return
+	}
+	res, err := Dump(flagDump)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	fmt.Println(res)
+	os.Exit(0)
+}
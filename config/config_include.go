@@ -0,0 +1,229 @@
+package config
+
+/*
+
+配置文件的include，以及多环境配置(profile)。
+
+include:
+	配置文件中可通过`include`引用其他配置文件，值为一个路径或路径列表，相对路径基于当前文件所在目录。
+	支持yaml(.yaml, .yml)、json(.json)、toml(.toml)格式，按扩展名识别，其他扩展名按yaml处理。
+	合并顺序：按列表顺序依次合并被引用的文件，最后合并当前文件自身的内容，即当前文件优先级最高。
+	被引用的文件必须存在。循环引用将返回错误。
+
+profiles:
+	配置文件中可通过`profiles`定义多套环境配置，如：
+
+		db_host: 127.0.0.1
+		profiles:
+		  prod:
+		    db_host: 10.0.0.1
+
+	所有配置文件加载完成之后，按命令行参数`-profile`或环境变量ProfileEnv选择profile，
+	覆盖到配置文件的配置之上，优先级低于环境变量、命令行参数`-set`。
+	可用','分隔指定多个profile，按顺序依次覆盖。指定的profile不存在时返回错误。
+
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+const INCLUDE_KEY = "include"
+const PROFILES_KEY = "profiles"
+
+// 选择profile的环境变量名称
+var ProfileEnv = "CONFIG_PROFILE"
+
+var flagProfile string
+
+type fileLoader struct {
+	stack  []string // 当前的include链，用于检测循环引用
+	files  []string // 所有加载过的文件，用于热加载时检查文件变化
+	layers []layer  // 按合并顺序记录的配置来源
+}
+
+// 加载一个配置文件，以及其include的所有文件。
+func (l *fileLoader) load(path string) (map[string]interface{}, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range l.stack {
+		if item == absPath {
+			chain := append(append([]string{}, l.stack[i:]...), absPath)
+			return nil, fmt.Errorf("include cycle detected: %s", strings.Join(chain, " -> "))
+		}
+	}
+	l.stack = append(l.stack, absPath)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	fileData, err := readConfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file '%s'. %s", path, err.Error())
+	}
+	l.files = append(l.files, path)
+
+	// 解析include
+	includes, err := parseIncludes(fileData[INCLUDE_KEY])
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s' in config file '%s'. %s", INCLUDE_KEY, path, err.Error())
+	}
+	delete(fileData, INCLUDE_KEY)
+
+	data := map[string]interface{}{}
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		includeData, err := l.load(include)
+		if err != nil {
+			return nil, err
+		}
+		mergeMap(data, includeData)
+	}
+	mergeMap(data, fileData)
+	l.layers = append(l.layers, newLayer(path, fileData))
+	return data, nil
+}
+
+func parseIncludes(val interface{}) ([]string, error) {
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		includes := make([]string, 0, len(v))
+		for _, item := range v {
+			path, ok := item.(string)
+			if !ok || path == "" {
+				return nil, errors.New("must be a file path or a list of file paths")
+			}
+			includes = append(includes, path)
+		}
+		return includes, nil
+	}
+	return nil, errors.New("must be a file path or a list of file paths")
+}
+
+// 按扩展名解析配置文件
+func readConfigFile(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var raw map[string]interface{}
+		dec := json.NewDecoder(strings.NewReader(string(content)))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		for key, val := range raw {
+			data[key] = normalizeNumbers(val)
+		}
+	case ".toml":
+		var raw map[string]interface{}
+		if err := toml.Unmarshal(content, &raw); err != nil {
+			return nil, err
+		}
+		for key, val := range raw {
+			data[key] = normalizeNumbers(val)
+		}
+	default:
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// 将json、toml解析得到的数据转为与yaml一致的类型：整数为int，浮点数为float64，字典为map[interface{}]interface{}。
+func normalizeNumbers(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if num, err := v.Int64(); err == nil {
+			return int(num)
+		}
+		num, _ := v.Float64()
+		return num
+	case int64:
+		return int(v)
+	case map[string]interface{}:
+		res := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			res[key] = normalizeNumbers(item)
+		}
+		return res
+	case []map[string]interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = normalizeNumbers(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = normalizeNumbers(item)
+		}
+		return res
+	}
+	return val
+}
+
+// 当前选择的profile列表
+func selectedProfiles() []string {
+	selected := flagProfile
+	if selected == "" {
+		selected = os.Getenv(ProfileEnv)
+	}
+	profiles := []string{}
+	for _, name := range strings.Split(selected, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			profiles = append(profiles, name)
+		}
+	}
+	return profiles
+}
+
+// 将选择的profile覆盖到data之上，并移除profiles配置。返回各profile的配置来源。
+func applyProfiles(data map[string]interface{}) ([]layer, error) {
+	profilesRaw, ok := data[PROFILES_KEY]
+	delete(data, PROFILES_KEY)
+
+	var profiles map[string]interface{}
+	if ok {
+		if profiles, ok = toStringMap(profilesRaw); !ok {
+			return nil, fmt.Errorf("invalid '%s' config, expect a dict, got %T", PROFILES_KEY, profilesRaw)
+		}
+	}
+
+	layers := []layer{}
+	for _, name := range selectedProfiles() {
+		profileRaw, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("profile '%s' is not defined in config", name)
+		}
+		if profileRaw == nil {
+			continue
+		}
+		profile, ok := toStringMap(profileRaw)
+		if !ok {
+			return nil, fmt.Errorf("invalid profile '%s', expect a dict, got %T", name, profileRaw)
+		}
+		mergeMap(data, profile)
+		layers = append(layers, newLayer("profile:"+name, profile))
+	}
+	return layers, nil
+}
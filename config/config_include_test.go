@@ -0,0 +1,221 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 在dir下写入配置文件，key为相对路径
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 只从指定的配置文件加载，测试结束时恢复
+func loadFiles(t *testing.T, paths ...string) (*loaded, error) {
+	t.Helper()
+	oldFiles, oldPrefix := ConfigFiles, EnvPrefix
+	ConfigFiles, EnvPrefix = paths, ""
+	defer func() { ConfigFiles, EnvPrefix = oldFiles, oldPrefix }()
+	return load()
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.yaml":     "include: sub/b.yaml\n",
+		"sub/b.yaml": "include: [c.yaml]\n",
+		"sub/c.yaml": "include: ../a.yaml\n",
+	})
+	_, err := loadFiles(t, filepath.Join(dir, "a.yaml"))
+	if err == nil {
+		t.Fatal("expect an include cycle error")
+	}
+	chain := strings.Join([]string{
+		filepath.Join(dir, "a.yaml"), filepath.Join(dir, "sub/b.yaml"), filepath.Join(dir, "sub/c.yaml"), filepath.Join(dir, "a.yaml"),
+	}, " -> ")
+	if !strings.Contains(err.Error(), chain) {
+		t.Errorf("expect the chain %q in error, got %v", chain, err)
+	}
+
+	// 同一个文件被多次include，但不构成循环
+	writeFiles(t, dir, map[string]string{
+		"d.yaml":     "include: [sub/e.yaml, sub/e.yaml]\n",
+		"sub/e.yaml": "x: 1\n",
+	})
+	if _, err := loadFiles(t, filepath.Join(dir, "d.yaml")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestIncludeOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"conf/main.yaml": `
+include: [db/base.yaml, db/override.yaml]
+name: main
+db: {host: main}
+`,
+		// 相对路径基于当前文件所在目录
+		"conf/db/base.yaml":     "include: ../../shared/common.yaml\ndb: {host: base, port: 3306, user: base}\n",
+		"conf/db/override.yaml": "db: {port: 3307}\nname: override\n",
+		"shared/common.yaml":    "log_level: debug\ndb: {user: common, charset: utf8}\n",
+	})
+	res, err := loadFiles(t, filepath.Join(dir, "conf/main.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":      "main",
+		"log_level": "debug",
+		"db":        map[interface{}]interface{}{"host": "main", "port": 3307, "user": "base", "charset": "utf8"},
+	}
+	if !reflect.DeepEqual(res.data, want) {
+		t.Errorf("expect %v, got %v", want, res.data)
+	}
+	if len(res.files) != 4 {
+		t.Errorf("expect 4 loaded files, got %v", res.files)
+	}
+
+	// include的文件必须存在
+	writeFiles(t, dir, map[string]string{"bad.yaml": "include: missing.yaml\n"})
+	if _, err := loadFiles(t, filepath.Join(dir, "bad.yaml")); err == nil || !strings.Contains(err.Error(), "missing.yaml") {
+		t.Errorf("expect an error for missing include, got %v", err)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": `
+db_host: local
+db_port: 3306
+redis_info: {address: local, password: p}
+profiles:
+  prod:
+    db_host: prod
+    redis_info: {address: prod}
+  gray:
+    db_host: gray
+  empty:
+`,
+	})
+	path := filepath.Join(dir, "config.yaml")
+	oldProfile := flagProfile
+	defer func() { flagProfile = oldProfile }()
+
+	cases := []struct {
+		flag, env string
+		host      string
+		address   string
+	}{
+		{"", "", "local", "local"},
+		{"", "prod", "prod", "prod"},
+		{"prod, gray", "", "gray", "prod"}, // 按顺序依次覆盖
+		{"gray,prod", "", "prod", "prod"},
+		{"gray", "prod", "gray", "local"}, // 命令行参数优先于环境变量
+		{"empty", "", "local", "local"},
+	}
+	for _, c := range cases {
+		flagProfile = c.flag
+		t.Setenv(ProfileEnv, c.env)
+		res, err := loadFiles(t, path)
+		if err != nil {
+			t.Errorf("%q %q: %s", c.flag, c.env, err.Error())
+			continue
+		}
+		redis, _ := res.data["redis_info"].(map[interface{}]interface{})
+		if res.data["db_host"] != c.host || redis["address"] != c.address || redis["password"] != "p" || res.data["db_port"] != 3306 {
+			t.Errorf("%q %q: unexpected config %v", c.flag, c.env, res.data)
+		}
+		if _, ok := res.data[PROFILES_KEY]; ok {
+			t.Errorf("%q %q: profiles should be removed", c.flag, c.env)
+		}
+	}
+
+	// 环境变量、-set的优先级高于profile
+	flagProfile = "prod"
+	oldSets := flagSets
+	flagSets = stringList{"redis_info.address=flag"}
+	defer func() { flagSets = oldSets }()
+	res, err := loadFiles(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redis := res.data["redis_info"].(map[interface{}]interface{}); redis["address"] != "flag" {
+		t.Errorf("expect -set to override profile, got %v", redis["address"])
+	}
+	flagSets = oldSets
+
+	flagProfile = "missing"
+	if _, err := loadFiles(t, path); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expect an error for undefined profile, got %v", err)
+	}
+}
+
+func TestFileFormats(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": `
+name: app
+port: 8080
+ratio: 0.5
+debug: true
+tags: [a, b]
+db: {host: h, port: 3306}
+servers:
+  - {name: s1, weight: 1}
+  - {name: s2, weight: 2}
+`,
+		"config.json": `{
+	"name": "app", "port": 8080, "ratio": 0.5, "debug": true, "tags": ["a", "b"],
+	"db": {"host": "h", "port": 3306},
+	"servers": [{"name": "s1", "weight": 1}, {"name": "s2", "weight": 2}]
+}`,
+		"config.toml": `
+name = "app"
+port = 8080
+ratio = 0.5
+debug = true
+tags = ["a", "b"]
+
+[db]
+host = "h"
+port = 3306
+
+[[servers]]
+name = "s1"
+weight = 1
+
+[[servers]]
+name = "s2"
+weight = 2
+`,
+	})
+
+	yamlRes, err := loadFiles(t, filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"config.json", "config.toml"} {
+		res, err := loadFiles(t, filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(res.data, yamlRes.data) {
+			t.Errorf("%s: expect %#v, got %#v", name, yamlRes.data, res.data)
+		}
+	}
+}
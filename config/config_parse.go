@@ -2,15 +2,15 @@ package config
 
 /*
 
-要求一个配置文件`conf/config.yml`
+默认加载配置文件`conf/config.yaml`，并支持默认值、多个配置文件、环境变量、命令行参数的分层覆盖。
+
+详见config_source.go
 
 */
 
 import (
-	"io/fs"
 	"strings"
-
-	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+	"sync"
 )
 
 const DEFAULT_CONFIG_PATH = "conf/config.yaml"
@@ -18,45 +18,100 @@ const DEFAULT_LISTEN_ADDR = ":8000"
 const DEFAULT_LOG_LEVEL = "INFO"
 const DEFAULT_DEBUG_MOD = false
 
+// 通用配置。配置重新加载时会被替换，仅在启动阶段(Parse()之后、Watch()之前)或ChangeHandler中可直接读取，
+// 其他时候请使用GetLogLevel()等方法，以免与重新加载并发读写。
 var LogLevel string
 var DebugMod bool
 var ListenAddr string
 var AuthRedirectURL string
 
+// 同上，启动阶段之后请使用Raw()
 var RawData map[string]interface{}
 
+// 最近一次加载的所有配置文件，包括include的文件
+var loadedFiles []string
+
+// 每个配置项的来源，key为以'.'分隔的路径
+var sourceMap map[string]string
+
+// 保护以上配置全局变量。配置重新加载时，所有变量在同一把锁内整体替换，
+// 读者不会看到更新了一半的配置。RawData本身在发布后不再被修改。
+var mu sync.RWMutex
+
 func Parse() error {
-	RawData = map[string]interface{}{}
-	err := tools.ParseYaml(DEFAULT_CONFIG_PATH, &RawData)
+	// 分层加载配置。配件文件不存在时，加载默认配置，不报错。
+	res, err := load()
 	if err != nil {
-		// 配件文件不存在时，加载默认配置，不报错。
-		if _, ok := err.(*fs.PathError); !ok {
-			return err
-		}
+		return err
 	}
+	data := res.data
 
-	// 设置LogLevel
-	if val, ok := RawData["log_level"].(string); ok {
-		LogLevel = strings.ToUpper(val)
-	} else {
-		LogLevel = DEFAULT_LOG_LEVEL
+	// 解析文件、环境变量引用，以及加密值。
+	secrets, err := resolveSecrets(data)
+	if err != nil {
+		return err
 	}
 
-	// 设置DebugMod
-	if LogLevel == "DEBUG" {
-		DebugMod = true
+	// 配置校验，在配置生效之前完成。
+	if err := validate(data); err != nil {
+		return err
+	}
+
+	// 设置LogLevel
+	logLevel := DEFAULT_LOG_LEVEL
+	if val, ok := data["log_level"].(string); ok {
+		logLevel = strings.ToUpper(val)
 	}
 
 	// 设置ListenAddr
-	if val, ok := RawData["listen_addr"].(string); ok {
-		ListenAddr = val
-	} else {
-		ListenAddr = DEFAULT_LISTEN_ADDR
+	listenAddr := DEFAULT_LISTEN_ADDR
+	if val, ok := data["listen_addr"].(string); ok {
+		listenAddr = val
 	}
 
 	// 设置AuthRedirectURL
-	if val, ok := RawData["auth_redirect_url"].(string); ok {
-		AuthRedirectURL = val
-	}
+	authRedirectURL, _ := data["auth_redirect_url"].(string)
+
+	mu.Lock()
+	RawData = data
+	secretPaths = secrets
+	loadedFiles = res.files
+	sourceMap = res.sources
+	LogLevel = logLevel
+	DebugMod = DEFAULT_DEBUG_MOD || LogLevel == "DEBUG" // 设置DebugMod
+	ListenAddr = listenAddr
+	AuthRedirectURL = authRedirectURL
+	mu.Unlock()
 	return nil
 }
+
+func GetLogLevel() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return LogLevel
+}
+
+func GetDebugMod() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return DebugMod
+}
+
+func GetListenAddr() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return ListenAddr
+}
+
+func GetAuthRedirectURL() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return AuthRedirectURL
+}
+
+// 获取当前配置数据。配置重新加载后，之前获取的数据保持不变。
+func Raw() map[string]interface{} {
+	mu.RLock()
+	defer mu.RUnlock()
+	return RawData
+}
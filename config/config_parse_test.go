@@ -0,0 +1,22 @@
+package config
+
+import (
+	"testing"
+)
+
+// Parse()、Reload()不处理`-dump-config`，不会退出进程
+func TestParseIgnoresDumpFlag(t *testing.T) {
+	oldFiles, oldDump := ConfigFiles, flagDump
+	ConfigFiles, flagDump = nil, "yaml"
+	defer func() { ConfigFiles, flagDump = oldFiles, oldDump }()
+
+	if err := Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetLogLevel(); got != DEFAULT_LOG_LEVEL {
+		t.Errorf("expect log level %s, got %s", DEFAULT_LOG_LEVEL, got)
+	}
+}
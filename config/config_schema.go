@@ -0,0 +1,229 @@
+package config
+
+/*
+
+配置校验。
+
+各模块通过RegisterSchema()注册自己所属配置的校验规则，规则语法与validator.DataValidator(即ginstarter.DataValidator)一致。
+Parse()时，在配置生效之前统一校验，一次性报告所有未知的配置项、以及不符合规则的配置项。
+
+一个Schema仅在其所属配置存在时才会被校验，即：
+	- Section不为空时，要求Section存在。Section以`.*`结尾时，表示对该字典下的每一项分别校验，如`databases.*`。
+	- Section为空时(顶层配置)，要求存在Rules中的key，或者以KeyPrefix为前缀的key。
+
+与Bind()的类型转换保持一致：
+	- 除validator支持的类型外，还支持"duration"类型：与time.Duration字段一致，接受"30s"格式的字符串或表示秒数的数字，
+	  支持required及min、max约束(单位：秒)。
+	- "string"类型接受数字、bool，按字符串校验，如纯数字的密码。
+	- "int"、"float"、"bool"类型接受字符串，如来自环境变量的值。
+
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/validator"
+)
+
+type Schema struct {
+	Section   string // 配置段，以'.'表示嵌套，如`nebula_info`。为空表示顶层配置。以`.*`结尾表示字典下的每一项。
+	KeyPrefix string // 顶层配置专用。以此为前缀的key都归属于该Schema，不在Rules中的视为未知配置项。
+	Rules     map[string]map[string]interface{}
+}
+
+// 配置校验失败时返回的错误，汇总了所有未知、不合法的配置项。
+type SchemaError struct {
+	Errors []string
+}
+
+func (e *SchemaError) Error() string {
+	return "config validation failed. " + strings.Join(e.Errors, "; ")
+}
+
+type namedSchema struct {
+	name   string
+	schema Schema
+}
+
+var (
+	schemas    []namedSchema
+	schemaLock sync.Mutex
+)
+
+// 注册一个配置校验规则。name用于错误信息中标识模块，同名注册将覆盖之前的规则。
+func RegisterSchema(name string, schema Schema) {
+	schemaLock.Lock()
+	defer schemaLock.Unlock()
+	for i, item := range schemas {
+		if item.name == name {
+			schemas[i].schema = schema
+			return
+		}
+	}
+	schemas = append(schemas, namedSchema{name: name, schema: schema})
+}
+
+// 按已注册的规则校验配置数据。
+func validate(data map[string]interface{}) error {
+	schemaLock.Lock()
+	items := make([]namedSchema, len(schemas))
+	copy(items, schemas)
+	schemaLock.Unlock()
+
+	errs := []string{}
+	for _, item := range items {
+		errs = append(errs, validateSchema(data, item.name, item.schema)...)
+	}
+	if len(errs) > 0 {
+		return &SchemaError{Errors: errs}
+	}
+	return nil
+}
+
+func validateSchema(data map[string]interface{}, name string, schema Schema) []string {
+	errs := []string{}
+	addError := func(path string, msg string) {
+		errs = append(errs, fmt.Sprintf("[%s] '%s': %s", name, path, msg))
+	}
+
+	// 对字典下的每一项分别校验
+	if strings.HasSuffix(schema.Section, ".*") {
+		parentPath := strings.TrimSuffix(schema.Section, ".*")
+		parentVal, ok := getFrom(data, parentPath)
+		if !ok {
+			return nil
+		}
+		parent, ok := toStringMap(parentVal)
+		if !ok {
+			addError(parentPath, fmt.Sprintf("expect a dict, got %T", parentVal))
+			return errs
+		}
+		keys := make([]string, 0, len(parent))
+		for key := range parent {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			item := schema
+			item.Section = joinPath(parentPath, key)
+			errs = append(errs, validateSchema(data, name, item)...)
+		}
+		return errs
+	}
+
+	// 获取所属配置，不存在时不做校验。
+	var section map[string]interface{}
+	if schema.Section != "" {
+		val, ok := getFrom(data, schema.Section)
+		if !ok {
+			return nil
+		}
+		if section, ok = toStringMap(val); !ok {
+			addError(schema.Section, fmt.Sprintf("expect a dict, got %T", val))
+			return errs
+		}
+	} else {
+		section = map[string]interface{}{}
+		for key, val := range data {
+			if _, ok := schema.Rules[key]; ok || (schema.KeyPrefix != "" && strings.HasPrefix(key, schema.KeyPrefix)) {
+				section[key] = val
+			}
+		}
+		if len(section) == 0 {
+			return nil
+		}
+	}
+
+	// 校验数据可能被validator修改(如trim_space)，这里使用拷贝。
+	sectionCopy := make(map[string]interface{}, len(section))
+	for key, val := range section {
+		sectionCopy[key] = val
+	}
+
+	// 未知配置项
+	keys := make([]string, 0, len(sectionCopy))
+	for key := range sectionCopy {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := schema.Rules[key]; !ok {
+			addError(joinPath(schema.Section, key), "unknown config key")
+		}
+	}
+
+	// 逐个字段校验，以收集所有错误。
+	fields := make([]string, 0, len(schema.Rules))
+	for field := range schema.Rules {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		rule := schema.Rules[field]
+		if rule["type"] == "duration" {
+			if err := checkDuration(sectionCopy, field, rule); err != nil {
+				addError(joinPath(schema.Section, field), err.Error())
+			}
+			continue
+		}
+		v := validator.DataValidator{
+			Validator: map[string]map[string]interface{}{field: scalarRule(rule, sectionCopy, field)},
+		}
+		if err := v.DataValidate(sectionCopy); err != nil {
+			addError(joinPath(schema.Section, field), err.Error())
+		}
+	}
+	return errs
+}
+
+// 与Bind()的类型转换保持一致：int、float、bool类型的配置项为字符串时(如来自环境变量)，校验前先自动转换；
+// string类型的配置项为数字、bool时，转为字符串校验。
+func scalarRule(rule map[string]interface{}, data map[string]interface{}, field string) map[string]interface{} {
+	switch val := data[field].(type) {
+	case string:
+		switch rule["type"] {
+		case "int", "float", "bool":
+			res := make(map[string]interface{}, len(rule)+1)
+			for key, item := range rule {
+				res[key] = item
+			}
+			res["auto_convert"] = true
+			return res
+		}
+	case int, int64, uint64, float64, bool:
+		if rule["type"] == "string" {
+			data[field] = fmt.Sprintf("%v", val)
+		}
+	}
+	return rule
+}
+
+// 校验duration类型的配置项，解析规则与Bind()的time.Duration字段相同。
+func checkDuration(data map[string]interface{}, field string, rule map[string]interface{}) error {
+	val, ok := data[field]
+	if !ok {
+		if required, _ := rule["required"].(bool); required {
+			return fmt.Errorf("field `%s` must be provided", field)
+		}
+		return nil
+	}
+
+	var d time.Duration
+	b := &binder{}
+	b.decodeDuration(field, val, reflect.ValueOf(&d).Elem())
+	if len(b.errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(b.errors, "; "))
+	}
+	if min, ok := toFloat64(rule["min"]); ok && d.Seconds() < min {
+		return fmt.Errorf("value must be >= %vs, got %s", min, d)
+	}
+	if max, ok := toFloat64(rule["max"]); ok && d.Seconds() > max {
+		return fmt.Errorf("value must be <= %vs, got %s", max, d)
+	}
+	return nil
+}
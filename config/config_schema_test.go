@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestValidateSchemaTypes(t *testing.T) {
+	schema := Schema{
+		KeyPrefix: "test_",
+		Rules: map[string]map[string]interface{}{
+			"test_timeout":  {"type": "duration", "min": 0, "max": 3600},
+			"test_password": {"type": "string"},
+			"test_port":     {"type": "int", "min": 1},
+		},
+	}
+	cases := []struct {
+		name  string
+		data  map[string]interface{}
+		valid bool
+	}{
+		{"duration string", map[string]interface{}{"test_timeout": "30s"}, true},
+		{"duration seconds", map[string]interface{}{"test_timeout": 30}, true},
+		{"duration float seconds", map[string]interface{}{"test_timeout": 0.5}, true},
+		{"duration numeric string", map[string]interface{}{"test_timeout": "30"}, true},
+		{"duration invalid", map[string]interface{}{"test_timeout": "soon"}, false},
+		{"duration below min", map[string]interface{}{"test_timeout": "-1s"}, false},
+		{"duration above max", map[string]interface{}{"test_timeout": "2h"}, false},
+		{"numeric password", map[string]interface{}{"test_password": 123}, true},
+		{"bool password", map[string]interface{}{"test_password": true}, true},
+		{"list password", map[string]interface{}{"test_password": []interface{}{"a"}}, false},
+		{"int from string", map[string]interface{}{"test_port": "3306"}, true},
+		{"int from invalid string", map[string]interface{}{"test_port": "abc"}, false},
+	}
+	for _, c := range cases {
+		errs := validateSchema(c.data, "test", schema)
+		if valid := len(errs) == 0; valid != c.valid {
+			t.Errorf("%s: expect valid=%v, got errors %v", c.name, c.valid, errs)
+		}
+	}
+}
+
+func TestValidateDurationRequired(t *testing.T) {
+	schema := Schema{
+		Section: "test",
+		Rules: map[string]map[string]interface{}{
+			"timeout": {"type": "duration", "required": true},
+			"name":    {"type": "string"},
+		},
+	}
+	data := map[string]interface{}{"test": map[interface{}]interface{}{"name": "a"}}
+	if errs := validateSchema(data, "test", schema); len(errs) != 1 {
+		t.Errorf("expect a required error, got %v", errs)
+	}
+}
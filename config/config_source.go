@@ -0,0 +1,245 @@
+package config
+
+/*
+
+配置分层加载。优先级由低到高依次为：
+
+	1. 内置默认值: Defaults，可通过SetDefault()设置。
+	2. 配置文件: ConfigFiles，以及命令行参数`-config`指定的文件。后加载的文件覆盖先加载的。
+	   配置文件支持include其他文件，以及profiles多环境配置，详见config_include.go。
+	3. 环境变量: 以EnvPrefix为前缀。如`TOOLBOX_DB_HOST`对应`db_host`，
+	   双下划线表示嵌套，如`TOOLBOX_NEBULA_INFO__HOST`对应`nebula_info.host`。
+	4. 命令行参数: `-set key=value`，key以'.'表示嵌套。需先调用RegisterFlags()。
+
+环境变量、命令行参数的值均作为字符串，由Bind()及配置校验按目标类型转换，如`TOOLBOX_DB_PASSWORD=0123`保持为"0123"。
+需要指定类型时，使用yaml的类型标记作为前缀，如`!!int 8080`、`!!bool true`、`!!float 1e3`。
+
+合并规则：字典类型的配置做深度合并，其他类型直接覆盖。
+嵌套字典统一为`map[interface{}]interface{}`类型，与yaml解析结果保持一致。
+
+*/
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const DEFAULT_ENV_PREFIX = "TOOLBOX_"
+
+// 内置默认值，优先级最低。
+var Defaults = map[string]interface{}{}
+
+// 依次加载的配置文件。文件不存在时将被忽略。
+var ConfigFiles = []string{DEFAULT_CONFIG_PATH}
+
+// 环境变量前缀，为空表示不从环境变量加载配置。
+var EnvPrefix = DEFAULT_ENV_PREFIX
+
+// 命令行参数
+var (
+	flagFiles stringList
+	flagSets  stringList
+)
+
+// 可重复指定的命令行参数
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(val string) error {
+	*s = append(*s, val)
+	return nil
+}
+
+// 设置一个默认值。key以'.'表示嵌套，如`nebula_info.port`
+func SetDefault(key string, val interface{}) {
+	setPath(Defaults, strings.Split(key, "."), normalizeValue(val))
+}
+
+// 追加一个配置文件，优先级高于已有的配置文件。
+func AddConfigFile(path string) {
+	ConfigFiles = append(ConfigFiles, path)
+}
+
+// 将配置相关的命令行参数注册到fs中，fs为nil时注册到flag.CommandLine。
+// 需在flag解析之后再调用Parse()，然后调用HandleFlags()处理`-dump-config`。
+//
+//	-config <path>		追加一个配置文件，可多次指定。文件必须存在。
+//	-set <key>=<value>	覆盖一个配置项，key以'.'表示嵌套，可多次指定。
+//	-profile <name>		选择profile，多个以','分隔。优先级高于环境变量ProfileEnv。
+//	-dump-config <fmt>	由HandleFlags()处理：以yaml或json格式输出生效的配置(已脱敏)，然后退出进程。
+func RegisterFlags(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	fs.Var(&flagFiles, "config", "config file path, can be specified multiple times")
+	fs.Var(&flagSets, "set", "override a config item, in form of 'key=value', can be specified multiple times")
+	fs.StringVar(&flagProfile, "profile", "", "config profiles to activate, separated by ','")
+	fs.StringVar(&flagDump, "dump-config", "", "print the effective config in 'yaml' or 'json' format, then exit")
+}
+
+// 一次加载的结果
+type loaded struct {
+	data    map[string]interface{}
+	files   []string          // 加载过的所有配置文件
+	sources map[string]string // 每个配置项的来源
+}
+
+// 按优先级加载所有配置源，返回合并后的数据。
+func load() (*loaded, error) {
+	data := map[string]interface{}{}
+
+	// 默认值
+	mergeMap(data, Defaults)
+	layers := []layer{newLayer(SOURCE_DEFAULT, Defaults)}
+
+	// 配置文件
+	loader := &fileLoader{}
+	for _, path := range ConfigFiles {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		fileData, err := loader.load(path)
+		if err != nil {
+			return nil, err
+		}
+		mergeMap(data, fileData)
+	}
+	for _, path := range flagFiles {
+		fileData, err := loader.load(path)
+		if err != nil {
+			return nil, err
+		}
+		mergeMap(data, fileData)
+	}
+	layers = append(layers, loader.layers...)
+
+	// 环境配置
+	profileLayers, err := applyProfiles(data)
+	if err != nil {
+		return nil, err
+	}
+	layers = append(layers, profileLayers...)
+
+	// 环境变量
+	if EnvPrefix != "" {
+		for _, env := range os.Environ() {
+			if !strings.HasPrefix(env, EnvPrefix) {
+				continue
+			}
+			kv := strings.SplitN(strings.TrimPrefix(env, EnvPrefix), "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				continue
+			}
+			path := strings.Split(strings.ToLower(kv[0]), "__")
+			setPath(data, path, parseScalar(kv[1]))
+			layers = append(layers, pathLayer("env:"+EnvPrefix+kv[0], path))
+		}
+	}
+
+	// 命令行参数
+	for _, item := range flagSets {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid flag '-set %s', must be in form of 'key=value'", item)
+		}
+		path := strings.Split(strings.TrimSpace(kv[0]), ".")
+		setPath(data, path, parseScalar(kv[1]))
+		layers = append(layers, pathLayer("flag:-set", path))
+	}
+
+	return &loaded{data: data, files: loader.files, sources: resolveSources(data, layers)}, nil
+}
+
+// 环境变量、命令行参数的值保持为原字符串。以`!!`开头时(如`!!int 8080`)，按yaml的类型标记解析，解析失败时保留原字符串。
+func parseScalar(raw string) interface{} {
+	if !strings.HasPrefix(strings.TrimSpace(raw), "!!") {
+		return raw
+	}
+	var val interface{}
+	if err := yaml.Unmarshal([]byte(raw), &val); err != nil || val == nil {
+		return raw
+	}
+	return val
+}
+
+// 将src深度合并到dst中。
+func mergeMap(dst map[string]interface{}, src map[string]interface{}) {
+	for key, val := range src {
+		dst[key] = mergeValue(dst[key], val)
+	}
+}
+
+func mergeValue(dstVal interface{}, srcVal interface{}) interface{} {
+	srcMap, ok := normalizeValue(srcVal).(map[interface{}]interface{})
+	if !ok {
+		return normalizeValue(srcVal)
+	}
+	dstMap, ok := dstVal.(map[interface{}]interface{})
+	if !ok {
+		return srcMap
+	}
+	merged := make(map[interface{}]interface{}, len(dstMap)+len(srcMap))
+	for key, val := range dstMap {
+		merged[key] = val
+	}
+	for key, val := range srcMap {
+		merged[key] = mergeValue(merged[key], val)
+	}
+	return merged
+}
+
+// 深拷贝一个配置值，并将所有嵌套字典统一转为map[interface{}]interface{}。
+func normalizeValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		res := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			res[key] = normalizeValue(item)
+		}
+		return res
+	case map[interface{}]interface{}:
+		res := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			res[key] = normalizeValue(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = normalizeValue(item)
+		}
+		return res
+	}
+	return val
+}
+
+// 按路径设置配置值，中间缺失或类型不符的节点将被替换为字典。
+func setPath(data map[string]interface{}, path []string, val interface{}) {
+	if len(path) == 1 {
+		data[path[0]] = val
+		return
+	}
+	node, ok := data[path[0]].(map[interface{}]interface{})
+	if !ok {
+		node = map[interface{}]interface{}{}
+		data[path[0]] = node
+	}
+	for _, key := range path[1 : len(path)-1] {
+		next, ok := node[key].(map[interface{}]interface{})
+		if !ok {
+			next = map[interface{}]interface{}{}
+			node[key] = next
+		}
+		node = next
+	}
+	node[path[len(path)-1]] = val
+}
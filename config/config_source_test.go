@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseScalar(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want interface{}
+	}{
+		{"0123", "0123"},
+		{"yes", "yes"},
+		{"1e3", "1e3"},
+		{"8080", "8080"},
+		{"", ""},
+		{"!!int 8080", 8080},
+		{"!!bool true", true},
+		{"!!float 1e3", 1000.0},
+		{"!!int abc", "!!int abc"},
+	}
+	for _, c := range cases {
+		if got := parseScalar(c.raw); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseScalar(%q) = %#v, want %#v", c.raw, got, c.want)
+		}
+	}
+}
+
+func TestEnvOverrideValidateAndBind(t *testing.T) {
+	oldFiles := ConfigFiles
+	ConfigFiles = nil
+	defer func() { ConfigFiles = oldFiles }()
+	t.Setenv(EnvPrefix+"TEST_PASSWORD", "0123")
+	t.Setenv(EnvPrefix+"TEST_PORT", "3307")
+	t.Setenv(EnvPrefix+"TEST_ENABLED", "yes")
+
+	res, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := Schema{
+		KeyPrefix: "test_",
+		Rules: map[string]map[string]interface{}{
+			"test_password": {"type": "string"},
+			"test_port":     {"type": "int", "min": 1, "max": 65535},
+			"test_enabled":  {"type": "bool"},
+		},
+	}
+	errs := validateSchema(res.data, "test", schema)
+	if len(errs) != 1 {
+		t.Fatalf("expect only test_enabled to be invalid, got %v", errs)
+	}
+
+	t.Setenv(EnvPrefix+"TEST_ENABLED", "true")
+	if res, err = load(); err != nil {
+		t.Fatal(err)
+	}
+	if errs := validateSchema(res.data, "test", schema); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	conf := struct {
+		Password string `config:"test_password"`
+		Port     int    `config:"test_port"`
+		Enabled  bool   `config:"test_enabled"`
+	}{}
+	b := &binder{}
+	b.decode("", res.data, reflect.ValueOf(&conf).Elem())
+	if len(b.errors) > 0 {
+		t.Fatal(b.errors)
+	}
+	if conf.Password != "0123" || conf.Port != 3307 || !conf.Enabled {
+		t.Errorf("unexpected bind result %+v", conf)
+	}
+}
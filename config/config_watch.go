@@ -0,0 +1,157 @@
+package config
+
+/*
+
+配置热加载。
+
+Watch()启动后，定时检查配置文件的变化(mtime, size)，或者收到SIGHUP信号时，重新加载配置。
+加载失败时保留原有配置。加载成功后，按key比较新旧配置值，通知订阅了该key的ChangeHandler。
+
+*/
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+)
+
+const DEFAULT_WATCH_INTERVAL = 5 * time.Second
+
+// 配置变化时的回调。key为订阅时的key，oldVal、newVal不存在时为nil。
+type ChangeHandler func(key string, oldVal interface{}, newVal interface{})
+
+type subscriber struct {
+	key     string
+	handler ChangeHandler
+}
+
+var (
+	subscribers []subscriber
+	subLock     sync.Mutex
+
+	reloadLock  sync.Mutex
+	stopWatch   chan struct{}
+	watchExited chan struct{}
+	watchLock   sync.Mutex
+)
+
+// 订阅某个配置项的变化。key以'.'表示嵌套，字典类型的配置项，任一子项变化都会触发回调。
+// 回调在配置全部更新完成之后执行，此时可直接读取新的全局配置变量。
+func Subscribe(key string, handler ChangeHandler) {
+	subLock.Lock()
+	defer subLock.Unlock()
+	subscribers = append(subscribers, subscriber{key: key, handler: handler})
+}
+
+// 重新加载配置，并通知订阅者。加载失败时，保留原有配置并返回错误。
+func Reload() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	oldData := Raw()
+	if err := Parse(); err != nil {
+		return err
+	}
+	newData := Raw()
+
+	subLock.Lock()
+	subs := make([]subscriber, len(subscribers))
+	copy(subs, subscribers)
+	subLock.Unlock()
+
+	for _, sub := range subs {
+		oldVal, _ := getFrom(oldData, sub.key)
+		newVal, _ := getFrom(newData, sub.key)
+		if !reflect.DeepEqual(oldVal, newVal) {
+			sub.handler(sub.key, oldVal, newVal)
+		}
+	}
+	return nil
+}
+
+// 开始监听配置变化，interval为配置文件的检查间隔，<=0时使用默认值。
+// 重复调用时，先停止之前的监听。
+func Watch(interval time.Duration) {
+	if interval <= 0 {
+		interval = DEFAULT_WATCH_INTERVAL
+	}
+
+	watchLock.Lock()
+	defer watchLock.Unlock()
+	stopWatching()
+	stop, exited := make(chan struct{}), make(chan struct{})
+	stopWatch, watchExited = stop, exited
+
+	go func() {
+		defer close(exited)
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		states := fileStates()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hup:
+				slog.Info("config.Watch(): SIGHUP received, reloading config.")
+			case <-ticker.C:
+				current := fileStates()
+				if reflect.DeepEqual(states, current) {
+					continue
+				}
+				slog.Info("config.Watch(): config file changed, reloading config.")
+			}
+			states = fileStates()
+			if err := Reload(); err != nil {
+				slog.Error(fmt.Sprintf("config.Watch(): failed to reload config, keep the old one. %s", err.Error()))
+			}
+		}
+	}()
+}
+
+// 停止监听配置变化。返回时不会再有进行中的重新加载，因此不能在ChangeHandler中调用。
+func StopWatch() {
+	watchLock.Lock()
+	defer watchLock.Unlock()
+	stopWatching()
+}
+
+func stopWatching() {
+	if stopWatch != nil {
+		close(stopWatch)
+		<-watchExited
+		stopWatch, watchExited = nil, nil
+	}
+}
+
+type fileState struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// 获取所有配置文件的状态，不存在的文件状态为零值。
+func fileStates() map[string]fileState {
+	states := map[string]fileState{}
+	mu.RLock()
+	paths := append(append(append([]string{}, ConfigFiles...), flagFiles...), loadedFiles...)
+	mu.RUnlock()
+	for _, path := range paths {
+		state := fileState{}
+		if info, err := os.Stat(path); err == nil {
+			state.ModTime = info.ModTime()
+			state.Size = info.Size()
+		}
+		states[path] = state
+	}
+	return states
+}
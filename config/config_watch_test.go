@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试结束时移除测试中注册的订阅者
+func restoreSubscribers(t *testing.T) {
+	subLock.Lock()
+	old := subscribers
+	subLock.Unlock()
+	t.Cleanup(func() {
+		subLock.Lock()
+		subscribers = old
+		subLock.Unlock()
+	})
+}
+
+func TestWatchNotifiesChangedKeys(t *testing.T) {
+	restoreSubscribers(t)
+	path := useConfig(t, `
+log_level: info
+db_host: a
+redis_info:
+  address: r1
+  password: p
+`)
+
+	var lock sync.Mutex
+	fired := []string{}
+	changed := make(chan struct{}, 10)
+	handler := func(key string, oldVal, newVal interface{}) {
+		lock.Lock()
+		fired = append(fired, key)
+		lock.Unlock()
+		changed <- struct{}{}
+	}
+	for _, key := range []string{"log_level", "db_host", "redis_info", "redis_info.password", "missing"} {
+		Subscribe(key, handler)
+	}
+
+	Watch(10 * time.Millisecond)
+	defer StopWatch()
+	// mtime的精度可能较低，内容长度不同以确保能检测到变化
+	time.Sleep(20 * time.Millisecond)
+	content := `
+log_level: debug
+db_host: a
+redis_info:
+  address: r2
+  password: p
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-changed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("config change not detected")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+	sort.Strings(fired)
+	if len(fired) != 2 || fired[0] != "log_level" || fired[1] != "redis_info" {
+		t.Errorf("expect log_level and redis_info to fire, got %v", fired)
+	}
+	if GetLogLevel() != "DEBUG" || !GetDebugMod() {
+		t.Errorf("expect new log level, got %s", GetLogLevel())
+	}
+}
+
+func TestReloadFailureKeepsOldConfig(t *testing.T) {
+	restoreSubscribers(t)
+	path := useConfig(t, "log_level: warning\ndb_host: a\n")
+
+	fired := false
+	Subscribe("db_host", func(key string, oldVal, newVal interface{}) {
+		fired = true
+	})
+
+	if err := os.WriteFile(path, []byte("log_level: warning\ndb_host: [b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err == nil {
+		t.Fatal("expect an error for invalid yaml")
+	}
+	if val, _ := Get("db_host"); val != "a" || GetLogLevel() != "WARNING" || fired {
+		t.Errorf("expect the old config to be kept, got db_host %v, log level %s", val, GetLogLevel())
+	}
+
+	if err := os.WriteFile(path, []byte("log_level: warning\ndb_host: b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := Get("db_host"); val != "b" || !fired {
+		t.Errorf("expect db_host to be reloaded, got %v", val)
+	}
+}
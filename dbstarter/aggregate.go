@@ -0,0 +1,224 @@
+package dbstarter
+
+/*
+
+聚合查询。
+
+	group_by=status,created_at__day     分组字段，以逗号分隔。日期时间字段可加`__hour`、`__day`、`__week`后缀按时间分桶，week以周一为起始
+	agg=count,sum:amount,avg:latency    聚合函数，以逗号分隔。支持count、count:<字段>、sum、avg、min、max，默认为count
+
+分组字段须在Serializor.GroupableFields中声明，聚合字段须在Serializor.AggregateFields中声明。
+结果中，分组字段以group_by中的名称(如`created_at__day`)为key，聚合结果以`<函数>_<字段>`(如`sum_amount`)为key，count为`count`。
+过滤、搜索与ListQuery相同；ordering只能使用结果中的key，默认按分组字段升序；分页参数应用于分组后的结果。
+
+*/
+
+import (
+	"fmt"
+	"strings"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	BUCKET_HOUR = "hour"
+	BUCKET_DAY  = "day"
+	BUCKET_WEEK = "week"
+)
+
+const (
+	AGG_COUNT = "count"
+	AGG_SUM   = "sum"
+	AGG_AVG   = "avg"
+	AGG_MIN   = "min"
+	AGG_MAX   = "max"
+)
+
+func GetTimeBuckets() []string {
+	return []string{BUCKET_HOUR, BUCKET_DAY, BUCKET_WEEK}
+}
+
+func GetAggregateFuncs() []string {
+	return []string{AGG_COUNT, AGG_SUM, AGG_AVG, AGG_MIN, AGG_MAX}
+}
+
+// 结果中的一列
+type aggColumn struct {
+	alias string
+	sql   string
+	vars  []interface{}
+}
+
+// 是否为聚合查询
+func (query *QueryData) IsAggregate() bool {
+	return query.GroupBy != "" || query.Agg != ""
+}
+
+func splitList(str string) []string {
+	items := []string{}
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// 按driver生成时间分桶的表达式
+func bucketColumn(driver string, bucket string, field string) aggColumn {
+	col := clause.Column{Name: field}
+	column := aggColumn{alias: field + FILTER_SEP + bucket, vars: []interface{}{col}}
+	switch driver {
+	case DRIVER_POSTGRES:
+		column.sql = fmt.Sprintf("date_trunc('%s', ?)", bucket)
+	case DRIVER_SQLITE:
+		switch bucket {
+		case BUCKET_HOUR:
+			column.sql = "strftime('%Y-%m-%d %H:00:00', ?)"
+		case BUCKET_DAY:
+			column.sql = "date(?)"
+		default:
+			column.sql = "date(?, '-6 days', 'weekday 1')"
+		}
+	default:
+		switch bucket {
+		case BUCKET_HOUR:
+			column.sql = "DATE_FORMAT(?, '%Y-%m-%d %H:00:00')"
+		case BUCKET_DAY:
+			column.sql = "DATE(?)"
+		default:
+			column.sql = "DATE(DATE_SUB(?, INTERVAL WEEKDAY(?) DAY))"
+			column.vars = append(column.vars, col)
+		}
+	}
+	return column
+}
+
+func (slz *Serializor) groupColumns(driver string, groupBy string) ([]aggColumn, error) {
+	columns := []aggColumn{}
+	for _, item := range splitList(groupBy) {
+		field, bucket := item, ""
+		if idx := strings.LastIndex(item, FILTER_SEP); idx > 0 && tools.IsStrInSlice(item[idx+len(FILTER_SEP):], GetTimeBuckets()) {
+			field, bucket = item[:idx], item[idx+len(FILTER_SEP):]
+		}
+		if !tools.IsStrInSlice(field, slz.GroupableFields) {
+			return nil, &QueryError{Key: "group_by", Msg: fmt.Sprintf("grouping by '%s' is not allowed", field)}
+		}
+		if bucket == "" {
+			columns = append(columns, aggColumn{alias: item, sql: "?", vars: []interface{}{clause.Column{Name: field}}})
+		} else {
+			columns = append(columns, bucketColumn(driver, bucket, field))
+		}
+	}
+	return columns, nil
+}
+
+func (slz *Serializor) aggColumns(agg string) ([]aggColumn, error) {
+	items := splitList(agg)
+	if len(items) == 0 {
+		items = []string{AGG_COUNT}
+	}
+	columns := []aggColumn{}
+	for _, item := range items {
+		fn, field := item, ""
+		if idx := strings.Index(item, ":"); idx >= 0 {
+			fn, field = item[:idx], item[idx+1:]
+		}
+		if !tools.IsStrInSlice(fn, GetAggregateFuncs()) {
+			return nil, &QueryError{Key: "agg", Msg: fmt.Sprintf("unsupported aggregate function '%s', must be one of %v", fn, GetAggregateFuncs())}
+		}
+		if fn == AGG_COUNT && field == "" {
+			columns = append(columns, aggColumn{alias: AGG_COUNT, sql: "COUNT(*)"})
+			continue
+		}
+		if field == "" {
+			return nil, &QueryError{Key: "agg", Msg: fmt.Sprintf("aggregate function '%s' requires a field, such as '%s:amount'", fn, fn)}
+		}
+		if !tools.IsStrInSlice(field, slz.AggregateFields) {
+			return nil, &QueryError{Key: "agg", Msg: fmt.Sprintf("aggregating on '%s' is not allowed", field)}
+		}
+		columns = append(columns, aggColumn{
+			alias: fn + "_" + field,
+			sql:   strings.ToUpper(fn) + "(?)",
+			vars:  []interface{}{clause.Column{Name: field}},
+		})
+	}
+	return columns, nil
+}
+
+// 聚合查询，返回分组的数量，及查询结果的*gorm.DB，用法与ListQuery相同。
+func (slz *Serializor) AggregateQuery(table string, queryData map[string]interface{}) (int64, *gorm.DB, error) {
+	query := slz.parseQuery(queryData)
+	dbtx, err := slz.baseQuery(table, query)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	groups, err := slz.groupColumns(dbtx.Dialector.Name(), query.GroupBy)
+	if err != nil {
+		return 0, nil, err
+	}
+	aggs, err := slz.aggColumns(query.Agg)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// select
+	selects := []string{}
+	vars := []interface{}{}
+	aliases := []string{}
+	for _, column := range append(append([]aggColumn{}, groups...), aggs...) {
+		selects = append(selects, column.sql+" AS ?")
+		vars = append(append(vars, column.vars...), clause.Column{Name: column.alias})
+		aliases = append(aliases, column.alias)
+	}
+	dbtx = dbtx.Clauses(clause.Select{Expression: clause.Expr{SQL: strings.Join(selects, ", "), Vars: vars}})
+
+	// group by，以序号表示分组列，避免重复分桶表达式
+	if len(groups) > 0 {
+		groupBy := clause.GroupBy{}
+		for i := range groups {
+			groupBy.Columns = append(groupBy.Columns, clause.Column{Name: fmt.Sprint(i + 1), Raw: true})
+		}
+		dbtx = dbtx.Clauses(groupBy)
+	}
+
+	// totalSize: 分组的数量
+	var totalSize int64
+	if len(groups) == 0 {
+		totalSize = 1
+	} else if err := dbtx.Session(&gorm.Session{NewDB: true}).Table("(?) AS agg", dbtx).Count(&totalSize).Error; err != nil {
+		return 0, nil, err
+	}
+
+	// order，只能使用结果中的key
+	if query.Ordering != "" {
+		orderSlz := &Serializor{OrderableFields: aliases}
+		columns, err := orderSlz.parseOrdering(query.Ordering)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, column := range columns {
+			dbtx = dbtx.Order(column)
+		}
+	} else {
+		for _, group := range groups {
+			dbtx = dbtx.Order(clause.OrderByColumn{Column: clause.Column{Name: group.alias}})
+		}
+	}
+
+	// pagination
+	if query.Pagination {
+		if query.PageIndex <= 0 || query.PageSize <= 0 {
+			query.PageIndex = DefaultPageIndex
+			query.PageSize = DefaultPageSize
+		}
+		offset := (query.PageIndex - 1) * query.PageSize
+		dbtx = dbtx.Offset(offset).Limit(query.PageSize)
+	}
+
+	return totalSize, dbtx, nil
+}
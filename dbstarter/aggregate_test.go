@@ -0,0 +1,123 @@
+package dbstarter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const testSalesDDL = `CREATE TABLE sales (
+	id INTEGER PRIMARY KEY,
+	status TEXT NOT NULL,
+	amount INTEGER NOT NULL,
+	created_at TEXT NOT NULL
+)`
+
+// 2022-03-07、2022-03-14为周一
+const testSalesData = `INSERT INTO sales (id, status, amount, created_at) VALUES
+	(1, 'paid', 10, '2022-03-07 09:15:00'),
+	(2, 'paid', 20, '2022-03-07 10:30:00'),
+	(3, 'new', 5, '2022-03-09 09:00:00'),
+	(4, 'paid', 30, '2022-03-13 23:00:00'),
+	(5, 'refund', 7, '2022-03-14 08:00:00')`
+
+func TestAggregateQuery(t *testing.T) {
+	conn, _ := newTestDB(t, testSalesDDL, testSalesData)
+	slz := &Serializor{
+		Conn:            conn,
+		FilterFields:    []string{"status"},
+		GroupableFields: []string{"status", "created_at"},
+		AggregateFields: []string{"amount"},
+	}
+
+	cases := []struct {
+		query     map[string]interface{}
+		totalSize int64
+		rows      []string // fmt.Sprint(row)，map按key排序输出
+	}{
+		{
+			map[string]interface{}{"agg": "count,sum:amount,max:amount"},
+			1, []string{"map[count:5 max_amount:30 sum_amount:72]"},
+		},
+		{
+			map[string]interface{}{"group_by": "status", "agg": "count,sum:amount"},
+			3, []string{"map[count:1 status:new sum_amount:5]", "map[count:3 status:paid sum_amount:60]", "map[count:1 status:refund sum_amount:7]"},
+		},
+		{
+			map[string]interface{}{"group_by": "status"},
+			3, []string{"map[count:1 status:new]", "map[count:3 status:paid]", "map[count:1 status:refund]"},
+		},
+		{
+			map[string]interface{}{"group_by": "status", "agg": "sum:amount", "ordering": "-sum_amount"},
+			3, []string{"map[status:paid sum_amount:60]", "map[status:refund sum_amount:7]", "map[status:new sum_amount:5]"},
+		},
+		{
+			map[string]interface{}{"group_by": "status", "ordering": "-count,status", "pagination": true, "page_index": 2, "page_size": 1},
+			3, []string{"map[count:1 status:new]"},
+		},
+		{
+			map[string]interface{}{"group_by": "created_at__day", "status": "paid"},
+			2, []string{"map[count:2 created_at__day:2022-03-07]", "map[count:1 created_at__day:2022-03-13]"},
+		},
+		{
+			map[string]interface{}{"group_by": "created_at__week", "agg": "min:amount"},
+			2, []string{"map[created_at__week:2022-03-07 min_amount:5]", "map[created_at__week:2022-03-14 min_amount:7]"},
+		},
+		{
+			map[string]interface{}{"group_by": "created_at__hour,status", "status": "paid", "ordering": "-created_at__hour"},
+			3, []string{
+				"map[count:1 created_at__hour:2022-03-13 23:00:00 status:paid]",
+				"map[count:1 created_at__hour:2022-03-07 10:00:00 status:paid]",
+				"map[count:1 created_at__hour:2022-03-07 09:00:00 status:paid]",
+			},
+		},
+	}
+	for _, c := range cases {
+		totalSize, dbtx, err := slz.AggregateQuery("sales", c.query)
+		if err != nil {
+			t.Errorf("%v: %s", c.query, err.Error())
+			continue
+		}
+		results := []map[string]interface{}{}
+		if err := dbtx.Find(&results).Error; err != nil {
+			t.Errorf("%v: %s", c.query, err.Error())
+			continue
+		}
+		rows := []string{}
+		for _, result := range results {
+			// sqlite中表达式列没有声明类型，gorm以指针返回
+			for key, val := range result {
+				if val != nil {
+					result[key] = reflect.Indirect(reflect.ValueOf(val)).Interface()
+				}
+			}
+			rows = append(rows, fmt.Sprint(result))
+		}
+		if totalSize != c.totalSize || !reflect.DeepEqual(rows, c.rows) {
+			t.Errorf("%v: expect %d %v, got %d %v", c.query, c.totalSize, c.rows, totalSize, rows)
+		}
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	conn, _ := newTestDB(t, testSalesDDL, testSalesData)
+	slz := &Serializor{Conn: conn, GroupableFields: []string{"status", "created_at"}, AggregateFields: []string{"amount"}}
+
+	cases := []map[string]interface{}{
+		{"group_by": "id"},                             // 不在GroupableFields中
+		{"group_by": "amount__day"},                    // 分桶字段也需声明
+		{"group_by": "status", "agg": "median:amount"}, // 不支持的聚合函数
+		{"agg": "sum"},                                 // 缺少字段
+		{"agg": "sum:id"},                              // 不在AggregateFields中
+		{"group_by": "status", "ordering": "amount"},   // 只能按结果中的key排序
+		{"group_by": "status", "amount": 1},            // 未声明FilterFields
+	}
+	for _, query := range cases {
+		_, _, err := slz.AggregateQuery("sales", query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("%v: expect a QueryError, got %v", query, err)
+		}
+	}
+}
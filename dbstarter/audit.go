@@ -0,0 +1,52 @@
+package dbstarter
+
+import (
+	"time"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+
+	"gorm.io/gorm/clause"
+)
+
+// 审计字段，通过Serializor.AuditColumns启用，写操作时自动维护
+const (
+	AUDIT_CREATED_AT = "created_at"
+	AUDIT_UPDATED_AT = "updated_at"
+	AUDIT_CREATED_BY = "created_by"
+	AUDIT_UPDATED_BY = "updated_by"
+)
+
+func GetAuditColumns() []string {
+	return []string{AUDIT_CREATED_AT, AUDIT_UPDATED_AT, AUDIT_CREATED_BY, AUDIT_UPDATED_BY}
+}
+
+// 未被软删除的条件
+func notDeleted() clause.Expression {
+	return clause.Expr{SQL: "? IS NULL", Vars: []interface{}{clause.Column{Name: SOFT_DELETE_FIELD}}}
+}
+
+// 返回添加了审计字段的写入数据拷贝，不修改调用方传入的data。create为true时为新增，否则为更新(包括软删除)。
+// *_by字段的值为Serializor.User，为nil时不设置。
+func (slz *Serializor) withAuditColumns(data map[string]interface{}, create bool) map[string]interface{} {
+	res := make(map[string]interface{}, len(data)+len(slz.AuditColumns))
+	for key, val := range data {
+		res[key] = val
+	}
+	now := time.Now()
+	set := func(column string, val interface{}) {
+		if tools.IsStrInSlice(column, slz.AuditColumns) {
+			res[column] = val
+		}
+	}
+	set(AUDIT_UPDATED_AT, now)
+	if create {
+		set(AUDIT_CREATED_AT, now)
+	}
+	if slz.User != nil {
+		set(AUDIT_UPDATED_BY, slz.User)
+		if create {
+			set(AUDIT_CREATED_BY, slz.User)
+		}
+	}
+	return res
+}
@@ -0,0 +1,123 @@
+package dbstarter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testPostsDDL = `CREATE TABLE posts (
+	id INTEGER PRIMARY KEY,
+	title TEXT NOT NULL,
+	created_at DATETIME,
+	updated_at DATETIME,
+	created_by TEXT,
+	updated_by TEXT,
+	deleted_at DATETIME
+)`
+
+func TestAuditColumns(t *testing.T) {
+	conn, db := newTestDB(t, testPostsDDL)
+	slz := &Serializor{Conn: conn, WritableFields: []string{"title"}, AuditColumns: GetAuditColumns(), User: "alice"}
+
+	res, err := slz.Create("posts", map[string]interface{}{"title": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["created_by"] != "alice" || res["updated_by"] != "alice" || res["created_at"] == nil || res["updated_at"] == nil {
+		t.Errorf("unexpected audit columns after create %v", res)
+	}
+	id := res["id"]
+
+	// 更新时只修改updated_*
+	editor := *slz
+	editor.User = "bob"
+	time.Sleep(10 * time.Millisecond)
+	data := map[string]interface{}{"title": "b"}
+	if res, err = editor.Update("posts", id, data, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 {
+		t.Errorf("caller's data should not be modified, got %v", data)
+	}
+	createdAt, _ := res["created_at"].(time.Time)
+	updatedAt, _ := res["updated_at"].(time.Time)
+	if res["created_by"] != "alice" || res["updated_by"] != "bob" || !updatedAt.After(createdAt) {
+		t.Errorf("unexpected audit columns after update %v", res)
+	}
+
+	// User为nil时不修改*_by；未启用的审计字段不设置
+	partial := &Serializor{Conn: conn, WritableFields: []string{"title"}, AuditColumns: []string{AUDIT_CREATED_AT}}
+	if res, err = partial.Update("posts", id, map[string]interface{}{"title": "c"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if res["updated_by"] != "bob" || !reflect.DeepEqual(res["updated_at"], updatedAt) {
+		t.Errorf("audit columns should be kept %v", res)
+	}
+	if res, err = partial.Create("posts", map[string]interface{}{"title": "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if res["created_at"] == nil || res["updated_at"] != nil || res["created_by"] != nil {
+		t.Errorf("only created_at should be set %v", res)
+	}
+
+	var count int64
+	db.Table("posts").Where("updated_at IS NOT NULL").Count(&count)
+	if count != 1 {
+		t.Errorf("expect 1 row with updated_at, got %d", count)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	conn, db := newTestDB(t, testPostsDDL, "INSERT INTO posts (id, title) VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+	slz := &Serializor{Conn: conn, WritableFields: []string{"title"}, SoftDelete: true, AuditColumns: GetAuditColumns(), User: "alice", OrderBy: "id"}
+
+	if err := slz.Delete("posts", 2, true); err != nil {
+		t.Fatal(err)
+	}
+	// 记录仍在，设置了deleted_at及updated_*
+	row := map[string]interface{}{}
+	db.Table("posts").Where("id = 2").Take(&row)
+	if row["deleted_at"] == nil || row["updated_by"] != "alice" || row["updated_at"] == nil {
+		t.Errorf("unexpected row after soft delete %v", row)
+	}
+
+	cases := []struct {
+		query map[string]interface{}
+		ids   []int
+	}{
+		{map[string]interface{}{}, []int{1, 3}},
+		{map[string]interface{}{"include_deleted": true}, []int{1, 2, 3}},
+		{map[string]interface{}{"include_deleted": "false"}, []int{1, 3}},
+	}
+	for _, c := range cases {
+		ids, err := listIDs(t, slz, "posts", c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, c.ids) {
+			t.Errorf("%v: expect %v, got %v", c.query, c.ids, ids)
+		}
+	}
+
+	if _, err := slz.DetailQuery("posts", 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expect ErrNotFound for soft deleted record, got %v", err)
+	}
+	if _, err := slz.Update("posts", 2, map[string]interface{}{"title": "x"}, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expect ErrNotFound updating soft deleted record, got %v", err)
+	}
+	if err := slz.Delete("posts", 2, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expect ErrNotFound deleting soft deleted record twice, got %v", err)
+	}
+
+	// 硬删除
+	if err := slz.Delete("posts", 2, false); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Table("posts").Count(&count)
+	if count != 2 {
+		t.Errorf("expect 2 rows after hard delete, got %d", count)
+	}
+}
@@ -0,0 +1,232 @@
+package dbstarter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+	"codeops.didachuxing.com/lordaeron/go-toolbox/validator"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 软删除标记字段，为NULL表示未删除
+const SOFT_DELETE_FIELD = "deleted_at"
+
+// 记录不存在。DetailQuery、Update、Delete返回的NotFoundError均可通过errors.Is(err, ErrNotFound)判断。
+var ErrNotFound = gorm.ErrRecordNotFound
+
+type NotFoundError struct {
+	Table string
+	ID    interface{}
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s with id '%v' not found", e.Table, e.ID)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// 获取用于写操作的数据库连接(主库)
+func (slz *Serializor) writer() (*gorm.DB, error) {
+	db, err := getPrimary(slz.Conn)
+	if err != nil {
+		return nil, err
+	}
+	return slz.withContext(db), nil
+}
+
+func pkEq(id interface{}) clause.Expression {
+	return clause.Eq{Column: clause.Column{Name: PK_FIELD}, Value: id}
+}
+
+// softDelete为true时，排除已软删除的记录
+func detail(db *gorm.DB, table string, id interface{}, fields []string, softDelete bool) (map[string]interface{}, error) {
+	dbtx := db.Table(table).Where(pkEq(id))
+	if softDelete {
+		dbtx = dbtx.Where(notDeleted())
+	}
+	if len(fields) > 0 {
+		dbtx = dbtx.Select(fields)
+	}
+	res := map[string]interface{}{}
+	if err := dbtx.Take(&res).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, &NotFoundError{Table: table, ID: id}
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// 查询单条记录，返回DetailFields中的字段。记录不存在(或已软删除)时返回NotFoundError。
+func (slz *Serializor) DetailQuery(table string, id interface{}) (map[string]interface{}, error) {
+	db, err := slz.reader()
+	if err != nil {
+		return nil, err
+	}
+	return detail(db, table, id, slz.DetailFields, slz.SoftDelete)
+}
+
+// 校验写入数据：字段必须在WritableFields中，并通过Validator的校验。
+// partial为true时(部分更新)，忽略Validator中的required约束。
+func (slz *Serializor) checkWritable(data map[string]interface{}, partial bool) error {
+	fields := make([]string, 0, len(data))
+	for field := range data {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if !tools.IsStrInSlice(field, slz.WritableFields) {
+			return &QueryError{Key: field, Msg: fmt.Sprintf("field '%s' is not writable", field)}
+		}
+	}
+
+	if slz.Validator == nil {
+		return nil
+	}
+	v := slz.Validator
+	if partial {
+		v = partialValidator(v)
+	}
+	if err := v.DataValidate(data); err != nil {
+		return &QueryError{Msg: err.Error()}
+	}
+	return nil
+}
+
+// 去掉required约束的Validator拷贝
+func partialValidator(v *validator.DataValidator) *validator.DataValidator {
+	rules := make(map[string]map[string]interface{}, len(v.Validator))
+	for field, rule := range v.Validator {
+		ruleCopy := make(map[string]interface{}, len(rule))
+		for prop, val := range rule {
+			if prop != "required" {
+				ruleCopy[prop] = val
+			}
+		}
+		rules[field] = ruleCopy
+	}
+	return &validator.DataValidator{Validator: rules, FixedFields: v.FixedFields}
+}
+
+// 插入一条记录，返回其主键。data中指定了主键时直接使用，否则postgres、sqlite通过RETURNING获取，
+// mysql通过LAST_INSERT_ID()获取(需在插入所用的事务中)。
+// 不使用postgres的lastval()，因为插入触发的触发器可能使用了其他序列。
+func insert(tx *gorm.DB, table string, data map[string]interface{}) (interface{}, error) {
+	id, hasID := data[PK_FIELD]
+	dbtx := tx.Table(table)
+	returning := !hasID && tx.Dialector.Name() != DRIVER_MYSQL
+	if returning {
+		dbtx = dbtx.Clauses(clause.Returning{Columns: []clause.Column{{Name: PK_FIELD}}})
+	}
+	// RETURNING的结果会写入data，因此使用拷贝
+	row := make(map[string]interface{}, len(data))
+	for key, val := range data {
+		row[key] = val
+	}
+	if err := dbtx.Create(row).Error; err != nil {
+		return nil, err
+	}
+	switch {
+	case hasID:
+		return id, nil
+	case returning:
+		if id, ok := row[PK_FIELD]; ok {
+			return id, nil
+		}
+		return nil, fmt.Errorf("no %s returned from inserting into %s", PK_FIELD, table)
+	}
+	var lastID int64
+	err := tx.Raw("SELECT LAST_INSERT_ID()").Scan(&lastID).Error
+	return lastID, err
+}
+
+// 新增一条记录，返回新记录的DetailFields字段。
+func (slz *Serializor) Create(table string, data map[string]interface{}) (map[string]interface{}, error) {
+	db, err := slz.writer()
+	if err != nil {
+		return nil, err
+	}
+	if err := slz.checkWritable(data, false); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, &QueryError{Msg: "no data to create"}
+	}
+	data = slz.withAuditColumns(data, true)
+
+	var res map[string]interface{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		id, err := insert(tx, table, data)
+		if err != nil {
+			return err
+		}
+		res, err = detail(tx, table, id, slz.DetailFields, false)
+		return err
+	})
+	return res, err
+}
+
+// 更新一条记录，返回更新后的DetailFields字段。记录不存在(或已软删除)时返回NotFoundError。
+// partial为false时(全量更新)，data需满足Validator的required约束。
+func (slz *Serializor) Update(table string, id interface{}, data map[string]interface{}, partial bool) (map[string]interface{}, error) {
+	db, err := slz.writer()
+	if err != nil {
+		return nil, err
+	}
+	if err := slz.checkWritable(data, partial); err != nil {
+		return nil, err
+	}
+
+	var res map[string]interface{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 数据未变化时，mysql返回的影响行数为0，故先检查记录是否存在
+		var count int64
+		existTx := tx.Table(table).Where(pkEq(id))
+		if slz.SoftDelete {
+			existTx = existTx.Where(notDeleted())
+		}
+		if err := existTx.Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return &NotFoundError{Table: table, ID: id}
+		}
+		if len(data) > 0 {
+			if err := tx.Table(table).Where(pkEq(id)).Updates(slz.withAuditColumns(data, false)).Error; err != nil {
+				return err
+			}
+		}
+		res, err = detail(tx, table, id, slz.DetailFields, false)
+		return err
+	})
+	return res, err
+}
+
+// 删除一条记录。soft为true时，仅设置SOFT_DELETE_FIELD为当前时间。记录不存在(或已软删除)时返回NotFoundError。
+func (slz *Serializor) Delete(table string, id interface{}, soft bool) error {
+	db, err := slz.writer()
+	if err != nil {
+		return err
+	}
+
+	var result *gorm.DB
+	if soft {
+		data := slz.withAuditColumns(map[string]interface{}{SOFT_DELETE_FIELD: time.Now()}, false)
+		result = db.Table(table).Where(pkEq(id)).Where(notDeleted()).Updates(data)
+	} else {
+		result = db.Exec("DELETE FROM ? WHERE ?", clause.Table{Name: table}, pkEq(id))
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &NotFoundError{Table: table, ID: id}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package dbstarter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	conn, db := newTestDB(t, testUsersDDL, testUsersData,
+		"CREATE TABLE tokens (id TEXT PRIMARY KEY, name TEXT NOT NULL)",
+		"CREATE TABLE logs (id INTEGER PRIMARY KEY, msg TEXT)",
+		// 触发器中的插入不应影响返回的主键
+		`CREATE TRIGGER users_log AFTER INSERT ON users BEGIN
+			INSERT INTO logs (id, msg) VALUES (1000 + NEW.id, NEW.name);
+		END`)
+	slz := &Serializor{Conn: conn, WritableFields: []string{"id", "name", "age"}, DetailFields: []string{"id", "name"}}
+
+	data := map[string]interface{}{"name": "frank", "age": 20}
+	res, err := slz.Create("users", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, map[string]interface{}{"id": int64(6), "name": "frank"}) {
+		t.Errorf("unexpected result %v", res)
+	}
+	if len(data) != 2 {
+		t.Errorf("caller's data should not be modified, got %v", data)
+	}
+
+	if res, err = slz.Create("users", map[string]interface{}{"id": 100, "name": "gina", "age": 20}); err != nil {
+		t.Fatal(err)
+	}
+	if res["id"] != int64(100) {
+		t.Errorf("expect id 100, got %v", res["id"])
+	}
+
+	// 非自增主键
+	tokens := &Serializor{Conn: conn, WritableFields: []string{"id", "name"}}
+	if res, err = tokens.Create("tokens", map[string]interface{}{"id": "5b2e6f0c-uuid", "name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if res["id"] != "5b2e6f0c-uuid" || res["name"] != "a" {
+		t.Errorf("unexpected result %v", res)
+	}
+
+	var count int64
+	db.Table("logs").Count(&count)
+	if count != 2 {
+		t.Errorf("expect 2 rows inserted by trigger, got %d", count)
+	}
+
+	cases := []map[string]interface{}{
+		{},
+		{"name": "x", "note": "not writable"},
+	}
+	for _, data := range cases {
+		_, err := slz.Create("users", data)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("%v: expect a QueryError, got %v", data, err)
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	conn, _ := newTestDB(t, testUsersDDL, testUsersData,
+		"ALTER TABLE users ADD COLUMN deleted_at DATETIME",
+		"UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = 5")
+	slz := &Serializor{Conn: conn, WritableFields: []string{"name"}, SoftDelete: true}
+
+	for _, id := range []interface{}{404, 5, "abc"} {
+		if _, err := slz.DetailQuery("users", id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DetailQuery(%v): expect ErrNotFound, got %v", id, err)
+		}
+		if _, err := slz.Update("users", id, map[string]interface{}{"name": "x"}, true); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update(%v): expect ErrNotFound, got %v", id, err)
+		}
+		if err := slz.Delete("users", id, true); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete(%v, soft): expect ErrNotFound, got %v", id, err)
+		}
+	}
+	if err := slz.Delete("users", 404, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(404): expect ErrNotFound, got %v", err)
+	}
+	var notFound *NotFoundError
+	if _, err := slz.DetailQuery("users", 404); !errors.As(err, &notFound) || notFound.Table != "users" || notFound.ID != 404 {
+		t.Errorf("expect a NotFoundError for users 404, got %v", err)
+	}
+
+	// 存在的记录
+	res, err := slz.Update("users", 1, map[string]interface{}{"name": "Tom"}, true)
+	if err != nil || res["name"] != "Tom" {
+		t.Errorf("unexpected update result %v, %v", res, err)
+	}
+	if err := slz.Delete("users", 1, false); err != nil {
+		t.Error(err)
+	}
+	if _, err := slz.DetailQuery("users", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expect ErrNotFound after delete, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package dbstarter
+
+/*
+
+游标(keyset)分页。
+
+与ListQuery的offset分页不同，CursorQuery以上一页最后(或第一)一行的排序字段值作为查询条件，
+翻页的开销与页数无关，适用于大表。游标对调用方不透明，只能原样传回。
+
+排序由ordering参数或Serializor.OrderBy决定，并总是追加主键作为最后一个排序字段，以保证顺序唯一。
+排序字段的值不能为NULL。
+
+*/
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const PK_FIELD = "id"
+
+const (
+	CURSOR_NEXT = "next"
+	CURSOR_PREV = "prev"
+)
+
+// CursorQuery的查询结果
+type CursorPage struct {
+	Results    []map[string]interface{} `json:"results"`
+	NextCursor string                   `json:"next_cursor"` // 空表示没有下一页
+	PrevCursor string                   `json:"prev_cursor"` // 空表示没有上一页
+	TotalSize  int64                    `json:"total_size"`  // SkipCount为true时为-1
+}
+
+type cursorData struct {
+	Ordering  string        `json:"o"`
+	Direction string        `json:"d"`
+	Values    []cursorValue `json:"v"`
+}
+
+// time.Time需要保留类型，否则作为字符串与时间字段比较时，依赖数据库的隐式转换。
+type cursorValue struct {
+	Time  *time.Time  `json:"t,omitempty"`
+	Value interface{} `json:"v,omitempty"`
+}
+
+func encodeCursor(cursor cursorData) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(str string) (*cursorData, error) {
+	data, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, &QueryError{Key: "cursor", Msg: "malformed cursor"}
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	cursor := &cursorData{}
+	if err := decoder.Decode(cursor); err != nil {
+		return nil, &QueryError{Key: "cursor", Msg: "malformed cursor"}
+	}
+	if cursor.Direction != CURSOR_NEXT && cursor.Direction != CURSOR_PREV {
+		return nil, &QueryError{Key: "cursor", Msg: "malformed cursor"}
+	}
+	return cursor, nil
+}
+
+func (val cursorValue) value() interface{} {
+	if val.Time != nil {
+		return *val.Time
+	}
+	if num, ok := val.Value.(json.Number); ok {
+		if i, err := num.Int64(); err == nil {
+			return i
+		}
+		f, _ := num.Float64()
+		return f
+	}
+	return val.Value
+}
+
+func newCursorValue(val interface{}) cursorValue {
+	switch v := val.(type) {
+	case time.Time:
+		return cursorValue{Time: &v}
+	case []byte:
+		return cursorValue{Value: string(v)}
+	}
+	return cursorValue{Value: val}
+}
+
+// 结果集中的字段名，不含表名
+func resultKey(name string) string {
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+func orderingKey(columns []clause.OrderByColumn) string {
+	items := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column.Desc {
+			items = append(items, "-"+column.Column.Name)
+		} else {
+			items = append(items, column.Column.Name)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// 位于游标之后的条件：(c1 > v1) OR (c1 = v1 AND c2 > v2) OR ...，降序字段使用'<'。
+// backward为true时方向相反，用于向前翻页。
+func keysetExpr(columns []clause.OrderByColumn, values []interface{}, backward bool) clause.Expression {
+	ors := make([]clause.Expression, 0, len(columns))
+	for i, column := range columns {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: columns[j].Column, Value: values[j]})
+		}
+		if column.Desc != backward {
+			ands = append(ands, clause.Lt{Column: column.Column, Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: column.Column, Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	// 单个条件的OrConditions在Where中会以OR连接，这里直接返回该条件
+	if len(ors) == 1 {
+		return ors[0]
+	}
+	return clause.Or(ors...)
+}
+
+// 游标分页查询。page_size为每页数量，cursor为上一次返回的NextCursor或PrevCursor，为空时查询第一页。
+func (slz *Serializor) CursorQuery(table string, queryData map[string]interface{}) (*CursorPage, error) {
+	query := slz.parseQuery(queryData)
+	dbtx, err := slz.baseQuery(table, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// 排序字段，追加主键以保证顺序唯一
+	columns, err := slz.orderColumns(query.Ordering)
+	if err != nil {
+		return nil, err
+	}
+	hasPK := false
+	for _, column := range columns {
+		if resultKey(column.Column.Name) == PK_FIELD {
+			hasPK = true
+		}
+	}
+	if !hasPK {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: PK_FIELD}})
+	}
+	key := orderingKey(columns)
+
+	// 排序字段不在ListFields中时，需要额外查询，返回前移除
+	extraFields := []string{}
+	if len(slz.ListFields) > 0 {
+		for _, column := range columns {
+			if !tools.IsStrInSlice(column.Column.Name, slz.ListFields) && !tools.IsStrInSlice(resultKey(column.Column.Name), slz.ListFields) {
+				extraFields = append(extraFields, column.Column.Name)
+			}
+		}
+		dbtx = dbtx.Select(append(append([]string{}, slz.ListFields...), extraFields...))
+	}
+
+	// totalSize by condition
+	page := &CursorPage{TotalSize: -1}
+	if !query.SkipCount {
+		if err := dbtx.Session(&gorm.Session{}).Count(&page.TotalSize).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// 游标条件
+	backward := false
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Ordering != key || len(cursor.Values) != len(columns) {
+			return nil, &QueryError{Key: "cursor", Msg: "cursor does not match the ordering"}
+		}
+		values := make([]interface{}, 0, len(cursor.Values))
+		for _, val := range cursor.Values {
+			values = append(values, val.value())
+		}
+		backward = cursor.Direction == CURSOR_PREV
+		dbtx = dbtx.Where(keysetExpr(columns, values, backward))
+	}
+
+	// order，向前翻页时反向排序，取出后再翻转
+	for _, column := range columns {
+		column.Desc = column.Desc != backward
+		dbtx = dbtx.Order(column)
+	}
+
+	// 多取一行，用于判断是否还有更多数据
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	rows := []map[string]interface{}{}
+	if err := dbtx.Limit(pageSize + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	hasMore := len(rows) > pageSize
+	if hasMore {
+		rows = rows[:pageSize]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	// 生成游标
+	makeCursor := func(row map[string]interface{}, direction string) string {
+		values := make([]cursorValue, 0, len(columns))
+		for _, column := range columns {
+			values = append(values, newCursorValue(row[resultKey(column.Column.Name)]))
+		}
+		return encodeCursor(cursorData{Ordering: key, Direction: direction, Values: values})
+	}
+	if len(rows) > 0 {
+		if hasMore || backward {
+			page.NextCursor = makeCursor(rows[len(rows)-1], CURSOR_NEXT)
+		}
+		if (hasMore && backward) || (!backward && query.Cursor != "") {
+			page.PrevCursor = makeCursor(rows[0], CURSOR_PREV)
+		}
+	}
+
+	for _, row := range rows {
+		for _, field := range extraFields {
+			delete(row, resultKey(field))
+		}
+	}
+	page.Results = rows
+	return page, nil
+}
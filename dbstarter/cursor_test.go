@@ -0,0 +1,120 @@
+package dbstarter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// 结果集中的id
+func pageIDs(page *CursorPage) []int {
+	ids := []int{}
+	for _, row := range page.Results {
+		ids = append(ids, int(row["id"].(int64)))
+	}
+	return ids
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	conn, _ := newTestDB(t, testUsersDDL, testUsersData,
+		"INSERT INTO users (id, name, age) VALUES (6, 'Tom', 30), (7, 'zed', 30)")
+
+	cases := []struct {
+		ordering string
+		pages    [][]int
+	}{
+		{"", [][]int{{1, 2}, {3, 4}, {5, 6}, {7}}},
+		{"-age", [][]int{{5, 4}, {3, 6}, {7, 2}, {1}}},
+		{"age,-name", [][]int{{1, 2}, {7, 6}, {3, 4}, {5}}},
+	}
+	for _, c := range cases {
+		slz := &Serializor{Conn: conn, OrderableFields: []string{"name", "age"}, ListFields: []string{"id", "name"}}
+		query := map[string]interface{}{"page_size": "2", "ordering": c.ordering}
+
+		// 向后翻到最后一页
+		pages := []*CursorPage{}
+		cursor := ""
+		for {
+			query["cursor"] = cursor
+			page, err := slz.CursorQuery("users", query)
+			if err != nil {
+				t.Fatalf("%q: %s", c.ordering, err.Error())
+			}
+			pages = append(pages, page)
+			if page.NextCursor == "" {
+				break
+			}
+			if len(pages) > len(c.pages) {
+				t.Fatalf("%q: too many pages", c.ordering)
+			}
+			cursor = page.NextCursor
+		}
+		if len(pages) != len(c.pages) {
+			t.Fatalf("%q: expect %d pages, got %d", c.ordering, len(c.pages), len(pages))
+		}
+		for i, page := range pages {
+			if ids := pageIDs(page); !reflect.DeepEqual(ids, c.pages[i]) {
+				t.Errorf("%q: page %d expect %v, got %v", c.ordering, i, c.pages[i], ids)
+			}
+			if page.TotalSize != 7 {
+				t.Errorf("%q: expect total size 7, got %d", c.ordering, page.TotalSize)
+			}
+			if (page.PrevCursor == "") != (i == 0) {
+				t.Errorf("%q: page %d has unexpected prev cursor %q", c.ordering, i, page.PrevCursor)
+			}
+			// 排序字段不在ListFields中时不返回
+			if _, ok := page.Results[0]["age"]; ok {
+				t.Errorf("%q: unexpected field age in results", c.ordering)
+			}
+		}
+
+		// 从最后一页向前翻回第一页
+		query["skip_count"] = "true"
+		cursor = pages[len(pages)-1].PrevCursor
+		for i := len(pages) - 2; i >= 0; i-- {
+			query["cursor"] = cursor
+			page, err := slz.CursorQuery("users", query)
+			if err != nil {
+				t.Fatalf("%q: %s", c.ordering, err.Error())
+			}
+			if ids := pageIDs(page); !reflect.DeepEqual(ids, c.pages[i]) {
+				t.Errorf("%q: prev page %d expect %v, got %v", c.ordering, i, c.pages[i], ids)
+			}
+			if page.TotalSize != -1 {
+				t.Errorf("%q: expect total size -1 with skip_count, got %d", c.ordering, page.TotalSize)
+			}
+			if page.NextCursor == "" {
+				t.Errorf("%q: prev page %d has no next cursor", c.ordering, i)
+			}
+			if (page.PrevCursor == "") != (i == 0) {
+				t.Errorf("%q: prev page %d has unexpected prev cursor %q", c.ordering, i, page.PrevCursor)
+			}
+			cursor = page.PrevCursor
+		}
+	}
+}
+
+func TestCursorErrors(t *testing.T) {
+	conn, _ := newTestDB(t, testUsersDDL, testUsersData)
+	slz := &Serializor{Conn: conn, OrderableFields: []string{"name", "age"}}
+
+	page, err := slz.CursorQuery("users", map[string]interface{}{"page_size": 2, "ordering": "age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []map[string]interface{}{
+		{"cursor": "not base64!"},
+		{"cursor": encodeCursor(cursorData{Ordering: "age,id", Direction: "up"})},
+		{"cursor": page.NextCursor, "ordering": "-age"}, // 游标与排序不一致
+		{"cursor": page.NextCursor},                     // 默认排序与游标不一致
+		{"cursor": encodeCursor(cursorData{Ordering: "age,id", Direction: CURSOR_NEXT, Values: []cursorValue{{Value: 1}}})},
+		{"ordering": "note"},
+	}
+	for _, query := range cases {
+		_, err := slz.CursorQuery("users", query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("%v: expect a QueryError, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package dbstarter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mysqldrv "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	DRIVER_MYSQL    = "mysql"
+	DRIVER_POSTGRES = "postgres"
+	DRIVER_SQLITE   = "sqlite"
+)
+
+const DEFAULT_POSTGRES_PORT = 5432
+const DEFAULT_POSTGRES_CHARSET = "UTF8"
+const DEFAULT_MYSQL_TIMEZONE = "Local"
+const DEFAULT_POSTGRES_SSLMODE = "disable"
+const SQLITE_MEMORY = ":memory:"
+
+// 需另外注册的driver。sqlite依赖cgo，不默认链接，需导入dbstarter/sqlite包注册
+var (
+	dialectors    = map[string]func(dsn string) gorm.Dialector{}
+	dialectorLock sync.RWMutex
+)
+
+// 注册driver的gorm.Dialector构造函数，dsn为连接配置的name
+func RegisterDialector(driver string, open func(dsn string) gorm.Dialector) {
+	dialectorLock.Lock()
+	defer dialectorLock.Unlock()
+	dialectors[driver] = open
+}
+
+func getDialector(driver string) func(dsn string) gorm.Dialector {
+	dialectorLock.RLock()
+	defer dialectorLock.RUnlock()
+	return dialectors[driver]
+}
+
+func GetDriverOptions() []string {
+	return []string{DRIVER_MYSQL, DRIVER_POSTGRES, DRIVER_SQLITE}
+}
+
+func driverChoices() []interface{} {
+	choices := []interface{}{}
+	for _, driver := range GetDriverOptions() {
+		choices = append(choices, driver)
+	}
+	return choices
+}
+
+// 校验连接配置，并按driver填充默认值
+func (conf *ConnConfig) check() error {
+	if conf.Driver == "" {
+		conf.Driver = DRIVER_MYSQL
+	}
+
+	switch conf.Driver {
+	case DRIVER_MYSQL, DRIVER_POSTGRES:
+		if conf.Host == "" || conf.Name == "" || conf.User == "" {
+			return errors.New("invalid db config. host, name, user cannot be empty")
+		}
+	case DRIVER_SQLITE:
+		// sqlite的name为数据库文件路径
+		if conf.Name == "" {
+			return errors.New("invalid db config. name cannot be empty for sqlite, it should be a file path or ':memory:'")
+		}
+		if getDialector(DRIVER_SQLITE) == nil {
+			return errors.New("invalid db config. sqlite driver is not registered, import codeops.didachuxing.com/lordaeron/go-toolbox/dbstarter/sqlite")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid db config. unsupported driver '%s', must be one of %v", conf.Driver, GetDriverOptions())
+	}
+
+	if conf.Driver == DRIVER_MYSQL {
+		if conf.Port <= 0 {
+			conf.Port = DEFAULT_DB_PORT
+		}
+		if conf.Charset == "" {
+			conf.Charset = DEFAULT_CHARSET
+		}
+		if conf.Timezone == "" {
+			conf.Timezone = DEFAULT_MYSQL_TIMEZONE
+		}
+		if _, err := time.LoadLocation(conf.Timezone); err != nil {
+			return fmt.Errorf("invalid db config. %s", err.Error())
+		}
+	} else {
+		if conf.Port <= 0 {
+			conf.Port = DEFAULT_POSTGRES_PORT
+		}
+		if conf.Charset == "" {
+			conf.Charset = DEFAULT_POSTGRES_CHARSET
+		}
+		if conf.SSLMode == "" {
+			conf.SSLMode = DEFAULT_POSTGRES_SSLMODE
+		}
+	}
+	return nil
+}
+
+// 按driver生成gorm.Dialector，需先调用check()
+func (conf *ConnConfig) dialector() gorm.Dialector {
+	switch conf.Driver {
+	case DRIVER_POSTGRES:
+		params := []string{
+			"host=" + pgQuote(conf.Host),
+			fmt.Sprintf("port=%d", conf.Port),
+			"user=" + pgQuote(conf.User),
+			"password=" + pgQuote(conf.Password),
+			"dbname=" + pgQuote(conf.Name),
+			"sslmode=" + pgQuote(conf.SSLMode),
+			"client_encoding=" + pgQuote(conf.Charset),
+		}
+		if conf.Timezone != "" {
+			params = append(params, "TimeZone="+pgQuote(conf.Timezone))
+		}
+		return postgres.Open(strings.Join(params, " "))
+	case DRIVER_SQLITE:
+		return getDialector(DRIVER_SQLITE)(conf.Name)
+	}
+	return mysql.Open(conf.mysqlDSN())
+}
+
+// mysql的DSN。由驱动生成，用户名、密码中的'@'、'/'、':'等特殊字符无需转义。
+func (conf *ConnConfig) mysqlDSN() string {
+	cfg := mysqldrv.NewConfig()
+	cfg.User = conf.User
+	cfg.Passwd = conf.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(conf.Host, strconv.Itoa(conf.Port))
+	cfg.DBName = conf.Name
+	cfg.ParseTime = true
+	cfg.Loc, _ = time.LoadLocation(conf.Timezone) // 已在check()中校验
+	cfg.Params = map[string]string{"charset": conf.Charset}
+	return cfg.FormatDSN()
+}
+
+// postgres连接参数值的转义
+func pgQuote(val string) string {
+	if val != "" && !strings.ContainsAny(val, ` '\`) {
+		return val
+	}
+	val = strings.ReplaceAll(val, `\`, `\\`)
+	val = strings.ReplaceAll(val, `'`, `\'`)
+	return "'" + val + "'"
+}
@@ -0,0 +1,126 @@
+package dbstarter
+
+import (
+	"sync"
+	"testing"
+
+	mysqldrv "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// 打开一个sqlite内存数据库，以测试名称注册，并执行stmts初始化。测试结束时注销并关闭。
+func newTestDB(t *testing.T, stmts ...string) (string, *gorm.DB) {
+	t.Helper()
+	name := t.Name()
+	db, err := openCluster(name, ConnConfig{Driver: DRIVER_SQLITE, Name: SQLITE_MEMORY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("failed to exec '%s'. %s", stmt, err.Error())
+		}
+	}
+	t.Cleanup(func() {
+		connLock.Lock()
+		c := conns[name]
+		delete(conns, name)
+		connLock.Unlock()
+		if c != nil {
+			c.close(nil)
+		}
+	})
+	return name, db
+}
+
+func TestMysqlDSN(t *testing.T) {
+	passwords := []string{"secret", "p@ss", "a/b", "a:b", "a?b=c&d", "@/:?#%"}
+	for _, password := range passwords {
+		conf := ConnConfig{Host: "db.local", User: "root", Password: password, Name: "app"}
+		if err := conf.check(); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := mysqldrv.ParseDSN(conf.mysqlDSN())
+		if err != nil {
+			t.Errorf("password %q: invalid dsn '%s'. %s", password, conf.mysqlDSN(), err.Error())
+			continue
+		}
+		if cfg.Passwd != password || cfg.User != "root" || cfg.Addr != "db.local:3306" || cfg.DBName != "app" {
+			t.Errorf("password %q: dsn '%s' parsed as %+v", password, conf.mysqlDSN(), cfg)
+		}
+		if !cfg.ParseTime || cfg.Params["charset"] != DEFAULT_CHARSET || cfg.Loc.String() != "Local" {
+			t.Errorf("password %q: unexpected dsn options %+v", password, cfg)
+		}
+	}
+
+	conf := ConnConfig{Host: "db.local", User: "root", Name: "app", Timezone: "Nowhere/Invalid"}
+	if err := conf.check(); err == nil {
+		t.Error("expect an error for invalid timezone")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		conf  ConnConfig
+		valid bool
+		port  int
+	}{
+		{ConnConfig{Host: "h", User: "u", Name: "n"}, true, DEFAULT_DB_PORT},
+		{ConnConfig{Driver: DRIVER_POSTGRES, Host: "h", User: "u", Name: "n"}, true, DEFAULT_POSTGRES_PORT},
+		{ConnConfig{Driver: DRIVER_POSTGRES, Host: "h", Name: "n"}, false, 0},
+		{ConnConfig{Driver: DRIVER_SQLITE, Name: SQLITE_MEMORY}, true, 0},
+		{ConnConfig{Driver: DRIVER_SQLITE}, false, 0},
+		{ConnConfig{Driver: "oracle", Host: "h", User: "u", Name: "n"}, false, 0},
+	}
+	for _, c := range cases {
+		conf := c.conf
+		err := conf.check()
+		if (err == nil) != c.valid {
+			t.Errorf("%+v: expect valid=%v, got %v", c.conf, c.valid, err)
+			continue
+		}
+		if err == nil && conf.Port != c.port {
+			t.Errorf("%+v: expect port %d, got %d", c.conf, c.port, conf.Port)
+		}
+	}
+}
+
+func TestSqliteMemory(t *testing.T) {
+	_, db := newTestDB(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+
+	// 并发使用连接池时，看到的是同一个数据库
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := db.Exec("INSERT INTO items (name) VALUES ('a')").Error; err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var count int64
+	if err := db.Table("items").Count(&count).Error; err != nil || count != 10 {
+		t.Fatalf("expect 10 rows, got %d, %v", count, err)
+	}
+
+	// 重新设置连接池参数后仍只使用一个连接
+	if err := setPool(db, ConnConfig{Driver: DRIVER_SQLITE, Name: SQLITE_MEMORY, MaxOpenConns: 10}); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	if max := sqlDB.Stats().MaxOpenConnections; max != 1 {
+		t.Errorf("expect 1 max open connection, got %d", max)
+	}
+
+	// 另一个内存数据库相互独立
+	other, err := Open(ConnConfig{Driver: DRIVER_SQLITE, Name: SQLITE_MEMORY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(other, nil)
+	if other.Migrator().HasTable("items") {
+		t.Error("memory databases should be independent")
+	}
+}
@@ -0,0 +1,234 @@
+package dbstarter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+)
+
+const (
+	EXPORT_CSV    = "csv"
+	EXPORT_NDJSON = "ndjson"
+	EXPORT_XLSX   = "xlsx"
+)
+
+// 每导出多少行刷新一次输出
+const EXPORT_FLUSH_ROWS = 1000
+
+const EXPORT_TIME_FORMAT = "2006-01-02 15:04:05"
+
+func GetExportFormats() []string {
+	return []string{EXPORT_CSV, EXPORT_NDJSON, EXPORT_XLSX}
+}
+
+// 导出格式对应的Content-Type
+func ExportContentType(format string) string {
+	switch format {
+	case EXPORT_CSV:
+		return "text/csv; charset=utf-8"
+	case EXPORT_NDJSON:
+		return "application/x-ndjson"
+	case EXPORT_XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+type rowWriter interface {
+	header(columns []string) error
+	row(values []interface{}) error
+	flush() error // 将缓冲的数据写入底层io.Writer
+	close() error
+}
+
+func newRowWriter(w io.Writer, format string) (rowWriter, error) {
+	switch format {
+	case EXPORT_CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case EXPORT_NDJSON:
+		return &ndjsonWriter{w: w}, nil
+	case EXPORT_XLSX:
+		return newXlsxWriter(w)
+	}
+	return nil, &QueryError{Key: "format", Msg: fmt.Sprintf("unsupported export format '%s', must be one of %v", format, GetExportFormats())}
+}
+
+// 导出ListQuery的全部结果(忽略分页)到w，表头为ListFields，为空时为查询结果的全部字段。
+// 查询结果逐行读取并写出，内存占用与结果数量无关。w实现了Flush()时(如gin.ResponseWriter)，每EXPORT_FLUSH_ROWS行刷新一次。
+func (slz *Serializor) Export(w io.Writer, table string, queryData map[string]interface{}, format string) error {
+	writer, err := newRowWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	query := slz.parseQuery(queryData)
+	dbtx, err := slz.baseQuery(table, query)
+	if err != nil {
+		return err
+	}
+	if len(slz.ListFields) > 0 {
+		dbtx = dbtx.Select(slz.ListFields)
+	}
+	if dbtx, err = slz.order(dbtx, query.Ordering); err != nil {
+		return err
+	}
+
+	rows, err := dbtx.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	headers := columns
+	if len(slz.ListFields) == len(columns) {
+		headers = slz.ListFields
+	}
+	if err := writer.header(headers); err != nil {
+		return err
+	}
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	numeric := make([]bool, len(columnTypes))
+	for i, columnType := range columnTypes {
+		numeric[i] = isNumericType(columnType.DatabaseTypeName())
+	}
+
+	flusher, _ := w.(interface{ Flush() })
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for i, val := range values {
+			if b, ok := val.([]byte); ok {
+				values[i] = bytesValue(b, numeric[i])
+			}
+		}
+		if err := writer.row(values); err != nil {
+			return err
+		}
+		count++
+		if flusher != nil && count%EXPORT_FLUSH_ROWS == 0 {
+			if err := writer.flush(); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return writer.close()
+}
+
+// 数值类型的字段。mysql的DECIMAL，以及不使用预处理语句时的所有数值，均以[]byte返回。
+var numericTypes = []string{"TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR", "DECIMAL", "NUMERIC", "FLOAT", "DOUBLE", "REAL"}
+
+func isNumericType(typeName string) bool {
+	typeName = strings.ToUpper(typeName)
+	if idx := strings.IndexByte(typeName, '('); idx >= 0 {
+		typeName = typeName[:idx] // sqlite返回声明的类型，如DECIMAL(10,2)
+	}
+	typeName = strings.TrimPrefix(strings.TrimSpace(typeName), "UNSIGNED ")
+	return tools.IsStrInSlice(typeName, numericTypes)
+}
+
+// 将数据库返回的[]byte转换为导出的值：数值字段转换为json.Number，以便ndjson中为数字、xlsx中为数值单元格，其他转换为字符串
+func bytesValue(b []byte, numeric bool) interface{} {
+	if numeric {
+		if _, err := strconv.ParseFloat(string(b), 64); err == nil {
+			return json.Number(b)
+		}
+	}
+	return string(b)
+}
+
+// 导出时的字符串表示
+func exportString(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(EXPORT_TIME_FORMAT)
+	}
+	return fmt.Sprint(val)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) header(columns []string) error {
+	return cw.w.Write(columns)
+}
+
+func (cw *csvWriter) row(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, val := range values {
+		record[i] = exportString(val)
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) close() error {
+	return cw.flush()
+}
+
+type ndjsonWriter struct {
+	w       io.Writer
+	columns []string
+}
+
+func (nw *ndjsonWriter) header(columns []string) error {
+	nw.columns = make([]string, len(columns))
+	for i, column := range columns {
+		nw.columns[i] = resultKey(column)
+	}
+	return nil
+}
+
+func (nw *ndjsonWriter) row(values []interface{}) error {
+	record := make(map[string]interface{}, len(values))
+	for i, val := range values {
+		record[nw.columns[i]] = val
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = nw.w.Write(append(data, '\n'))
+	return err
+}
+
+func (nw *ndjsonWriter) flush() error {
+	return nil
+}
+
+func (nw *ndjsonWriter) close() error {
+	return nil
+}
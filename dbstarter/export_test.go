@@ -0,0 +1,160 @@
+package dbstarter
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testOrdersDDL = `CREATE TABLE orders (
+	id INTEGER PRIMARY KEY,
+	amount DECIMAL(10,2),
+	code BLOB,
+	note TEXT
+)`
+
+// amount以BLOB写入，模拟mysql以[]byte返回DECIMAL
+const testOrdersData = `INSERT INTO orders (id, amount, code, note) VALUES
+	(1, CAST('12.50' AS BLOB), CAST('007' AS BLOB), 'a' || char(1) || 'b' || char(11) || char(9) || 'c'),
+	(2, 3, NULL, '<x> & "y"'),
+	(3, NULL, CAST('x' AS BLOB), NULL)`
+
+func TestIsNumericType(t *testing.T) {
+	cases := map[string]bool{
+		"INT": true, "bigint": true, "DECIMAL(10,2)": true, "UNSIGNED BIGINT": true, "NUMERIC": true, "DOUBLE": true,
+		"VARCHAR": false, "TEXT": false, "BLOB": false, "DATETIME": false, "": false,
+	}
+	for typeName, want := range cases {
+		if got := isNumericType(typeName); got != want {
+			t.Errorf("isNumericType(%q) = %v, want %v", typeName, got, want)
+		}
+	}
+}
+
+func TestExportNdjson(t *testing.T) {
+	conn, _ := newTestDB(t, testOrdersDDL, testOrdersData)
+	slz := &Serializor{Conn: conn, OrderBy: "id"}
+
+	buf := &bytes.Buffer{}
+	if err := slz.Export(buf, "orders", nil, EXPORT_NDJSON); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{"id": json.Number("1"), "amount": json.Number("12.50"), "code": "007", "note": "a\x01b\x0b\tc"},
+		{"id": json.Number("2"), "amount": json.Number("3"), "code": nil, "note": `<x> & "y"`},
+		{"id": json.Number("3"), "amount": nil, "code": "x", "note": nil},
+	}
+	scanner := bufio.NewScanner(buf)
+	for i := 0; scanner.Scan(); i++ {
+		decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+		decoder.UseNumber()
+		record := map[string]interface{}{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("line %d: %s", i, err.Error())
+		}
+		if i >= len(want) || !reflect.DeepEqual(record, want[i]) {
+			t.Errorf("line %d: unexpected record %v", i, record)
+		}
+	}
+}
+
+func TestExportCsv(t *testing.T) {
+	conn, _ := newTestDB(t, testOrdersDDL, testOrdersData)
+	slz := &Serializor{Conn: conn, ListFields: []string{"id", "amount"}, OrderBy: "id"}
+
+	buf := &bytes.Buffer{}
+	if err := slz.Export(buf, "orders", nil, EXPORT_CSV); err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,amount\n1,12.50\n2,3\n3,\n"; buf.String() != want {
+		t.Errorf("expect %q, got %q", want, buf.String())
+	}
+
+	if err := slz.Export(buf, "orders", nil, "pdf"); err == nil {
+		t.Error("expect an error for unsupported format")
+	}
+}
+
+func TestExportXlsx(t *testing.T) {
+	conn, _ := newTestDB(t, testOrdersDDL, testOrdersData)
+	slz := &Serializor{Conn: conn, ListFields: []string{"amount", "note"}, OrderBy: "id"}
+
+	buf := &bytes.Buffer{}
+	if err := slz.Export(buf, "orders", nil, EXPORT_XLSX); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet []byte
+	for _, file := range zr.File {
+		if file.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := file.Open()
+			sheet, _ = ioutil.ReadAll(r)
+			r.Close()
+		}
+	}
+	if sheet == nil {
+		t.Fatal("sheet1.xml not found")
+	}
+
+	// 解析工作表，得到每个单元格的值
+	type cell struct {
+		Type   string `xml:"t,attr"`
+		Value  string `xml:"v"`
+		Inline string `xml:"is>t"`
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(sheet))
+	cells := []cell{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid sheet xml. %s", err.Error())
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "c" {
+			c := cell{}
+			if err := decoder.DecodeElement(&c, &start); err != nil {
+				t.Fatal(err)
+			}
+			cells = append(cells, c)
+		}
+	}
+	want := []cell{
+		{"inlineStr", "", "amount"}, {"inlineStr", "", "note"},
+		{"", "12.50", ""}, {"inlineStr", "", "ab\tc"},
+		{"", "3", ""}, {"inlineStr", "", `<x> & "y"`},
+		{}, {},
+	}
+	if !reflect.DeepEqual(cells, want) {
+		t.Errorf("expect %+v, got %+v", want, cells)
+	}
+	if bytes.ContainsRune(sheet, '\uFFFD') {
+		t.Error("invalid characters should be removed instead of replaced")
+	}
+}
+
+func TestStripInvalidXML(t *testing.T) {
+	cases := map[string]string{
+		"abc":                   "abc",
+		"a\x00b\x1fc":           "abc",
+		"tab\tnl\ncr\r":         "tab\tnl\ncr\r",
+		"中文 😀":                  "中文 😀",
+		"x\uFFFEy\uFFFFz\u0008": "xyz",
+	}
+	for str, want := range cases {
+		if got := stripInvalidXML(str); got != want {
+			t.Errorf("stripInvalidXML(%q) = %q, want %q", str, got, want)
+		}
+	}
+}
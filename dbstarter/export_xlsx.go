@@ -0,0 +1,152 @@
+package dbstarter
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 最简的xlsx文件：单个工作表，字符串使用inlineStr，无需sharedStrings。
+// 工作表在zip中逐行写出，无需在内存中保存全部数据。
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+const xlsxSheetHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const xlsxSheetTail = `</sheetData></worksheet>`
+
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+}
+
+func newXlsxWriter(w io.Writer) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, file.content); err != nil {
+			return nil, err
+		}
+	}
+
+	// 工作表须为最后一个文件，之后逐行写入
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(fw)}
+	if _, err := xw.sheet.WriteString(xlsxSheetHead); err != nil {
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (xw *xlsxWriter) header(columns []string) error {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = column
+	}
+	return xw.row(values)
+}
+
+func (xw *xlsxWriter) row(values []interface{}) error {
+	xw.sheet.WriteString("<row>")
+	for _, val := range values {
+		switch v := val.(type) {
+		case nil:
+			xw.sheet.WriteString("<c/>")
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			xw.sheet.WriteString(`<c><v>` + exportString(v) + `</v></c>`)
+		case float32:
+			xw.sheet.WriteString(`<c><v>` + strconv.FormatFloat(float64(v), 'g', -1, 32) + `</v></c>`)
+		case float64:
+			xw.sheet.WriteString(`<c><v>` + strconv.FormatFloat(v, 'g', -1, 64) + `</v></c>`)
+		case json.Number:
+			xw.sheet.WriteString(`<c><v>` + string(v) + `</v></c>`)
+		case bool:
+			b := "0"
+			if v {
+				b = "1"
+			}
+			xw.sheet.WriteString(`<c t="b"><v>` + b + `</v></c>`)
+		case time.Time:
+			xw.writeString(v.Format(EXPORT_TIME_FORMAT))
+		default:
+			xw.writeString(exportString(v))
+		}
+	}
+	_, err := xw.sheet.WriteString("</row>")
+	return err
+}
+
+// 移除XML 1.0不允许的字符，如\t、\n、\r以外的控制字符，否则Excel无法打开文件
+func stripInvalidXML(str string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20, r >= 0xD800 && r < 0xE000, r == 0xFFFE, r == 0xFFFF:
+			return -1
+		}
+		return r
+	}, str)
+}
+
+func (xw *xlsxWriter) writeString(str string) {
+	xw.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+	xml.EscapeText(xw.sheet, []byte(stripInvalidXML(str)))
+	xw.sheet.WriteString(`</t></is></c>`)
+}
+
+func (xw *xlsxWriter) flush() error {
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zw.Flush()
+}
+
+func (xw *xlsxWriter) close() error {
+	if _, err := xw.sheet.WriteString(xlsxSheetTail); err != nil {
+		return err
+	}
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zw.Close()
+}
@@ -0,0 +1,244 @@
+package dbstarter
+
+/*
+
+ListQuery的过滤条件。
+
+Filter的key为`<字段>__<操作符>`的形式，不带操作符时表示相等，与Django一致，如：
+
+	age__gte=18
+	name__icontains=tom
+	id__in=1,2,3
+	deleted_at__isnull=true
+	created_at__range=2022-01-01,2022-02-01
+
+字段必须在Serializor.FilterFields中声明，否则返回QueryError。
+in、range的值可以是list，或以逗号分隔的字符串；isnull的值可以是bool，或"true"/"false"/"1"/"0"。
+contains、startswith、endswith等的值按字面匹配，其中的`%`、`_`会被转义，不作为通配符。
+
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const FILTER_SEP = "__"
+
+const (
+	OP_EXACT       = "exact"
+	OP_IEXACT      = "iexact"
+	OP_NE          = "ne"
+	OP_GT          = "gt"
+	OP_GTE         = "gte"
+	OP_LT          = "lt"
+	OP_LTE         = "lte"
+	OP_IN          = "in"
+	OP_CONTAINS    = "contains"
+	OP_ICONTAINS   = "icontains"
+	OP_STARTSWITH  = "startswith"
+	OP_ISTARTSWITH = "istartswith"
+	OP_ENDSWITH    = "endswith"
+	OP_IENDSWITH   = "iendswith"
+	OP_ISNULL      = "isnull"
+	OP_RANGE       = "range"
+)
+
+func GetFilterOperators() []string {
+	return []string{
+		OP_EXACT, OP_IEXACT, OP_NE, OP_GT, OP_GTE, OP_LT, OP_LTE, OP_IN,
+		OP_CONTAINS, OP_ICONTAINS, OP_STARTSWITH, OP_ISTARTSWITH, OP_ENDSWITH, OP_IENDSWITH,
+		OP_ISNULL, OP_RANGE,
+	}
+}
+
+// 查询参数或写入数据不合法时返回的错误，如不允许的过滤字段、不支持的操作符、数据校验失败等。
+// 与数据库错误不同，调用方可据此返回4xx错误。Key为空时，Msg即完整的错误信息。
+type QueryError struct {
+	Key string
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	if e.Key == "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("invalid parameter '%s'. %s", e.Key, e.Msg)
+}
+
+// 将filter的key拆分为字段和操作符。后缀不是已知操作符时，整个key视为字段名。
+func splitFilterKey(key string) (string, string) {
+	idx := strings.LastIndex(key, FILTER_SEP)
+	if idx > 0 {
+		op := key[idx+len(FILTER_SEP):]
+		for _, item := range GetFilterOperators() {
+			if op == item {
+				return key[:idx], op
+			}
+		}
+	}
+	return key, ""
+}
+
+// 将filter转换为查询条件
+func (slz *Serializor) filter(dbtx *gorm.DB, filter map[string]interface{}) (*gorm.DB, error) {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, op := splitFilterKey(key)
+		if !tools.IsStrInSlice(field, slz.FilterFields) {
+			return nil, &QueryError{Key: key, Msg: fmt.Sprintf("filtering on '%s' is not allowed", field)}
+		}
+		if op == "" {
+			op = OP_EXACT
+		}
+		expr, err := filterExpr(field, op, filter[key])
+		if err != nil {
+			return nil, &QueryError{Key: key, Msg: err.Error()}
+		}
+		dbtx = dbtx.Where(expr)
+	}
+	return dbtx, nil
+}
+
+func filterExpr(field, op string, val interface{}) (clause.Expression, error) {
+	col := clause.Column{Name: field}
+	switch op {
+	case OP_EXACT:
+		return clause.Eq{Column: col, Value: val}, nil
+	case OP_IEXACT:
+		return clause.Expr{SQL: "LOWER(?) = LOWER(?)", Vars: []interface{}{col, fmt.Sprint(val)}}, nil
+	case OP_NE:
+		return clause.Neq{Column: col, Value: val}, nil
+	case OP_GT:
+		return clause.Gt{Column: col, Value: val}, nil
+	case OP_GTE:
+		return clause.Gte{Column: col, Value: val}, nil
+	case OP_LT:
+		return clause.Lt{Column: col, Value: val}, nil
+	case OP_LTE:
+		return clause.Lte{Column: col, Value: val}, nil
+	case OP_IN:
+		return clause.IN{Column: col, Values: toList(val)}, nil
+	case OP_CONTAINS:
+		return likeExpr(col, "%", val, "%", false), nil
+	case OP_ICONTAINS:
+		return likeExpr(col, "%", val, "%", true), nil
+	case OP_STARTSWITH:
+		return likeExpr(col, "", val, "%", false), nil
+	case OP_ISTARTSWITH:
+		return likeExpr(col, "", val, "%", true), nil
+	case OP_ENDSWITH:
+		return likeExpr(col, "%", val, "", false), nil
+	case OP_IENDSWITH:
+		return likeExpr(col, "%", val, "", true), nil
+	case OP_ISNULL:
+		isNull, ok := toBool(val)
+		if !ok {
+			return nil, fmt.Errorf("expect a bool, got '%v'", val)
+		}
+		if isNull {
+			return clause.Expr{SQL: "? IS NULL", Vars: []interface{}{col}}, nil
+		}
+		return clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{col}}, nil
+	case OP_RANGE:
+		bounds := toList(val)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("expect 2 values, got %d", len(bounds))
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{col, bounds[0], bounds[1]}}, nil
+	}
+	return nil, fmt.Errorf("unsupported operator '%s'", op)
+}
+
+// LIKE的转义字符。不使用'\'，因为它在mysql和postgres的字符串字面量中含义不同。
+const LIKE_ESCAPE = '!'
+
+var likeEscaper = strings.NewReplacer(
+	string(LIKE_ESCAPE), string(LIKE_ESCAPE)+string(LIKE_ESCAPE),
+	"%", string(LIKE_ESCAPE)+"%",
+	"_", string(LIKE_ESCAPE)+"_",
+)
+
+// 转义LIKE中的通配符，使其按字面匹配
+func EscapeLike(str string) string {
+	return likeEscaper.Replace(str)
+}
+
+// LIKE条件，val经EscapeLike转义后按字面匹配，前后加上prefix、suffix通配符。ignoreCase为true时不区分大小写。
+func likeExpr(col clause.Column, prefix string, val interface{}, suffix string, ignoreCase bool) clause.Expression {
+	pattern := prefix + EscapeLike(fmt.Sprint(val)) + suffix
+	sql := fmt.Sprintf("? LIKE ? ESCAPE '%c'", LIKE_ESCAPE)
+	if ignoreCase {
+		sql = fmt.Sprintf("LOWER(?) LIKE LOWER(?) ESCAPE '%c'", LIKE_ESCAPE)
+	}
+	return clause.Expr{SQL: sql, Vars: []interface{}{col, pattern}}
+}
+
+// list，或以逗号分隔的字符串
+func toList(val interface{}) []interface{} {
+	if str, ok := val.(string); ok {
+		res := []interface{}{}
+		for _, item := range strings.Split(str, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				res = append(res, item)
+			}
+		}
+		return res
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []interface{}{val}
+	}
+	res := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		res = append(res, rv.Index(i).Interface())
+	}
+	return res
+}
+
+func toInt(val interface{}) (int, bool) {
+	switch v := val.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), float64(int(v)) == v
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err == nil
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toBool(val interface{}) (bool, bool) {
+	switch v := val.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	}
+	// 数字只接受0、1
+	if i, ok := toInt(val); ok && (i == 0 || i == 1) {
+		return i == 1, true
+	}
+	return false, false
+}
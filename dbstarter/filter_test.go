@@ -0,0 +1,134 @@
+package dbstarter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testUsersDDL = `CREATE TABLE users (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	age INTEGER NOT NULL,
+	note TEXT
+)`
+
+const testUsersData = `INSERT INTO users (id, name, age, note) VALUES
+	(1, 'Tom', 18, NULL),
+	(2, 'tommy', 25, '50% off'),
+	(3, 'Alice', 30, 'a_b'),
+	(4, 'bob', 40, '100%'),
+	(5, 'eve', 50, 'wow!')`
+
+// 执行ListQuery，返回结果的id
+func listIDs(t *testing.T, slz *Serializor, table string, queryData map[string]interface{}) ([]int, error) {
+	t.Helper()
+	_, dbtx, err := slz.ListQuery(table, queryData)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	if err := dbtx.Pluck("id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ids, nil
+}
+
+func TestSplitFilterKey(t *testing.T) {
+	cases := []struct {
+		key, field, op string
+	}{
+		{"age", "age", ""},
+		{"age__gte", "age", OP_GTE},
+		{"name__icontains", "name", OP_ICONTAINS},
+		{"created_at__range", "created_at", OP_RANGE},
+		{"user__name", "user__name", ""},
+		{"user__name__in", "user__name", OP_IN},
+		{"__in", "__in", ""},
+	}
+	for _, c := range cases {
+		field, op := splitFilterKey(c.key)
+		if field != c.field || op != c.op {
+			t.Errorf("splitFilterKey(%q) = (%q, %q), want (%q, %q)", c.key, field, op, c.field, c.op)
+		}
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	conn, _ := newTestDB(t, testUsersDDL, testUsersData)
+	slz := &Serializor{Conn: conn, FilterFields: []string{"id", "name", "age", "note"}, OrderBy: "id"}
+
+	cases := []struct {
+		filter map[string]interface{}
+		ids    []int
+	}{
+		{map[string]interface{}{"age": 18}, []int{1}},
+		{map[string]interface{}{"age__exact": "25"}, []int{2}},
+		{map[string]interface{}{"age__ne": 18}, []int{2, 3, 4, 5}},
+		{map[string]interface{}{"age__gt": 25}, []int{3, 4, 5}},
+		{map[string]interface{}{"age__gte": "25"}, []int{2, 3, 4, 5}},
+		{map[string]interface{}{"age__lt": 25}, []int{1}},
+		{map[string]interface{}{"age__lte": 25}, []int{1, 2}},
+		{map[string]interface{}{"id__in": "1, 3"}, []int{1, 3}},
+		{map[string]interface{}{"id__in": []interface{}{"2", "4"}}, []int{2, 4}},
+		{map[string]interface{}{"age__range": "20,35"}, []int{2, 3}},
+		{map[string]interface{}{"age__range": []int{30, 50}}, []int{3, 4, 5}},
+		{map[string]interface{}{"note__isnull": true}, []int{1}},
+		{map[string]interface{}{"note__isnull": "false"}, []int{2, 3, 4, 5}},
+		{map[string]interface{}{"note__isnull": 1}, []int{1}},
+		{map[string]interface{}{"note__isnull": float64(0)}, []int{2, 3, 4, 5}},
+		{map[string]interface{}{"note__isnull": json.Number("1")}, []int{1}},
+		{map[string]interface{}{"note__isnull": int64(0)}, []int{2, 3, 4, 5}},
+		{map[string]interface{}{"name__iexact": "TOM"}, []int{1}},
+		{map[string]interface{}{"name__contains": "om"}, []int{1, 2}},
+		{map[string]interface{}{"name__icontains": "OM"}, []int{1, 2}},
+		{map[string]interface{}{"name__startswith": "Al"}, []int{3}},
+		{map[string]interface{}{"name__istartswith": "TO"}, []int{1, 2}},
+		{map[string]interface{}{"name__endswith": "my"}, []int{2}},
+		{map[string]interface{}{"name__iendswith": "OB"}, []int{4}},
+		{map[string]interface{}{"age__gte": 20, "name__icontains": "o"}, []int{2, 4}},
+
+		// LIKE的通配符及转义字符按字面匹配
+		{map[string]interface{}{"note__contains": "%"}, []int{2, 4}},
+		{map[string]interface{}{"note__icontains": "_"}, []int{3}},
+		{map[string]interface{}{"note__startswith": "50%"}, []int{2}},
+		{map[string]interface{}{"note__istartswith": "%"}, []int{}},
+		{map[string]interface{}{"note__endswith": "!"}, []int{5}},
+		{map[string]interface{}{"note__iendswith": "0%"}, []int{4}},
+	}
+	for _, c := range cases {
+		ids, err := listIDs(t, slz, "users", c.filter)
+		if err != nil {
+			t.Errorf("%v: %s", c.filter, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(ids, c.ids) {
+			t.Errorf("%v: expect %v, got %v", c.filter, c.ids, ids)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	conn, _ := newTestDB(t, testUsersDDL, testUsersData)
+	slz := &Serializor{Conn: conn, FilterFields: []string{"id", "name", "age"}}
+
+	cases := []map[string]interface{}{
+		{"note": "a"},            // 不在FilterFields中
+		{"note__contains": "a"},  // 带操作符的字段同样需要声明
+		{"age__foo": 1},          // 未知操作符，整个key视为字段名
+		{"age__isnull": "maybe"}, // isnull需要bool
+		{"age__isnull": 2},
+		{"age__isnull": 0.5},
+		{"age__range": "1"},       // range需要2个值
+		{"age__range": "1,2,3"},   // range需要2个值
+		{"name__in": "a", "x": 1}, // 任一key不合法
+	}
+	for _, filter := range cases {
+		_, err := listIDs(t, slz, "users", filter)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("%v: expect a QueryError, got %v", filter, err)
+		}
+	}
+}
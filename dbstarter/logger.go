@@ -0,0 +1,221 @@
+package dbstarter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 请求ID在context中的key。gin.Context中ctx.Set(CTX_REQUEST_ID_KEY, id)后，
+// 以db.WithContext(ginCtx)执行的SQL语句都会在日志中带上请求ID。
+const CTX_REQUEST_ID_KEY = tools.CTX_REQUEST_ID_KEY
+
+const DEFAULT_SLOW_THRESHOLD = 200 * time.Millisecond
+
+// SQL日志中是否代入参数值，由db_log_sql_params配置。参数中可能含有密码、手机号等敏感信息。
+const (
+	SQL_PARAMS_DEBUG = "debug" // 仅DEBUG级别的日志代入参数值，ERROR、WARNING级别只记录带占位符的语句。默认值
+	SQL_PARAMS_ALL   = "all"   // 所有日志都代入参数值
+	SQL_PARAMS_NONE  = "none"  // 所有日志都只记录带占位符的语句
+)
+
+func GetSQLParamsOptions() []string {
+	return []string{SQL_PARAMS_DEBUG, SQL_PARAMS_ALL, SQL_PARAMS_NONE}
+}
+
+// SQL日志的配置
+type logConfig struct {
+	SlowThreshold time.Duration `config:"db_slow_threshold" default:"200ms"` // 慢查询阈值，如"500ms"。0表示不记录慢查询
+	SQLParams     string        `config:"db_log_sql_params" default:"debug"` // 见SQL_PARAMS_*
+}
+
+// 慢查询阈值，需原子读写，支持配置热加载
+var slowThreshold = int64(DEFAULT_SLOW_THRESHOLD)
+
+// SQL_PARAMS_*之一，需原子读写，支持配置热加载
+var sqlParams atomic.Value
+
+// 设置慢查询阈值，耗时超过该值的SQL语句以WARNING级别记录。d<=0表示不记录慢查询。
+func SetSlowThreshold(d time.Duration) {
+	atomic.StoreInt64(&slowThreshold, int64(d))
+}
+
+// 设置SQL日志中是否代入参数值，mode为SQL_PARAMS_*之一
+func SetSQLParams(mode string) error {
+	for _, option := range GetSQLParamsOptions() {
+		if mode == option {
+			sqlParams.Store(mode)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sql params mode '%s', must be one of %v", mode, GetSQLParamsOptions())
+}
+
+// 该级别的SQL日志是否代入参数值
+func withSQLParams(level string) bool {
+	mode, _ := sqlParams.Load().(string)
+	switch mode {
+	case SQL_PARAMS_ALL:
+		return true
+	case SQL_PARAMS_NONE:
+		return false
+	}
+	return level == "DEBUG"
+}
+
+// 返回携带请求ID的context，以db.WithContext(ctx)执行的SQL语句将在日志中带上该请求ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, CTX_REQUEST_ID_KEY, requestID)
+}
+
+// 获取context中的请求ID，没有时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(CTX_REQUEST_ID_KEY).(string)
+	return id
+}
+
+// dbstarter所在目录，查找调用位置时跳过
+var sourceDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file) + string(filepath.Separator)
+}()
+
+// 执行SQL语句的业务代码位置，跳过gorm及dbstarter内部(测试除外)的调用
+func callerLocation() string {
+	pcs := make([]uintptr, 20)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.File, sourceDir) && !strings.HasSuffix(frame.File, "_test.go")
+		if !strings.Contains(frame.File, "gorm.io/") && !internal {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// 通过simplelog记录gorm自身的日志，在open()时安装。SQL语句由registerTraceCallbacks()记录。
+type sqlLogger struct{}
+
+// 日志级别由simplelog统一控制，忽略gorm的设置
+func (l sqlLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l sqlLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	slog.Info(logMessage(ctx, fmt.Sprintf(msg, data...)))
+}
+
+func (l sqlLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	slog.Warning(logMessage(ctx, fmt.Sprintf(msg, data...)))
+}
+
+func (l sqlLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	slog.Error(logMessage(ctx, fmt.Sprintf(msg, data...)))
+}
+
+// gorm传入的fc只能得到已代入参数的语句，因此不在此记录，见registerTraceCallbacks()
+func (l sqlLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+}
+
+const traceStartKey = "dbstarter:trace_start"
+
+// 在每种操作的所有回调前后，记录每一条SQL语句，附带耗时、影响行数、调用位置，以及context中的请求ID(如有)：
+//   - 执行出错(记录不存在除外)，以ERROR级别记录
+//   - 耗时超过慢查询阈值，以WARNING级别记录
+//   - 其余以DEBUG级别记录
+//
+// 先确定日志级别，该级别不输出时不再生成语句及查找调用位置。
+func registerTraceCallbacks(db *gorm.DB) error {
+	type register interface {
+		Register(name string, fn func(*gorm.DB)) error
+	}
+	cb := db.Callback()
+	pairs := [][2]register{
+		{cb.Create().Before("*"), cb.Create().After("*")},
+		{cb.Query().Before("*"), cb.Query().After("*")},
+		{cb.Update().Before("*"), cb.Update().After("*")},
+		{cb.Delete().Before("*"), cb.Delete().After("*")},
+		{cb.Row().Before("*"), cb.Row().After("*")},
+		{cb.Raw().Before("*"), cb.Raw().After("*")},
+	}
+	for _, pair := range pairs {
+		if err := pair[0].Register("dbstarter:trace_start", traceStart); err != nil {
+			return err
+		}
+		if err := pair[1].Register("dbstarter:trace", traceSQL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func traceStart(db *gorm.DB) {
+	db.InstanceSet(traceStartKey, time.Now())
+}
+
+func traceSQL(db *gorm.DB) {
+	stmt := db.Statement
+	if stmt.SQL.Len() == 0 {
+		return
+	}
+	var elapsed time.Duration
+	if begin, ok := db.InstanceGet(traceStartKey); ok {
+		elapsed = time.Since(begin.(time.Time))
+	}
+
+	threshold := time.Duration(atomic.LoadInt64(&slowThreshold))
+	level := "DEBUG"
+	switch {
+	case db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound):
+		level = "ERROR"
+	case threshold > 0 && elapsed > threshold:
+		level = "WARNING"
+	}
+	if !slog.Enabled(level) {
+		return
+	}
+
+	sql := stmt.SQL.String()
+	if withSQLParams(level) {
+		sql = db.Dialector.Explain(sql, stmt.Vars...)
+	}
+	rowsStr := "-"
+	if db.RowsAffected >= 0 {
+		rowsStr = fmt.Sprintf("%d", db.RowsAffected)
+	}
+	detail := fmt.Sprintf("[%.3fms] [rows:%s] %s (%s)", float64(elapsed.Nanoseconds())/1e6, rowsStr, sql, callerLocation())
+
+	switch level {
+	case "ERROR":
+		slog.Error(logMessage(stmt.Context, fmt.Sprintf("sql error %s. %s", detail, db.Error.Error())))
+	case "WARNING":
+		slog.Warning(logMessage(stmt.Context, fmt.Sprintf("slow sql > %s %s", threshold, detail)))
+	default:
+		slog.Debug(logMessage(stmt.Context, "sql "+detail))
+	}
+}
+
+func logMessage(ctx context.Context, msg string) string {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return fmt.Sprintf("dbstarter: [request_id:%s] %s", id, msg)
+	}
+	return "dbstarter: " + msg
+}
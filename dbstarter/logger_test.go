@@ -0,0 +1,82 @@
+package dbstarter
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+)
+
+// 捕获simplelog的输出，测试结束时恢复日志级别等设置
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		slog.SetLevel(slog.DEFAULT_TRIGGER_LEVEL)
+		SetSlowThreshold(DEFAULT_SLOW_THRESHOLD)
+		SetSQLParams(SQL_PARAMS_DEBUG)
+	})
+	return buf
+}
+
+func TestSQLLog(t *testing.T) {
+	_, db := newTestDB(t, "CREATE TABLE secrets (id INTEGER PRIMARY KEY, token TEXT)")
+	buf := captureLog(t)
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	cases := []struct {
+		level, params string
+		slow          bool
+		fail          bool
+		contains      []string
+		excludes      []string
+	}{
+		// 默认：DEBUG级别代入参数，ERROR、WARNING只记录占位符
+		{"DEBUG", SQL_PARAMS_DEBUG, false, false, []string{"[DEBUG]", "token = \"s3cret\"", "[request_id:req-1]", "logger_test.go:"}, nil},
+		{"DEBUG", SQL_PARAMS_DEBUG, false, true, []string{"[ERROR]", "no such column", "token = ?"}, []string{"s3cret"}},
+		{"DEBUG", SQL_PARAMS_DEBUG, true, false, []string{"[WARNING]", "slow sql", "token = ?"}, []string{"s3cret"}},
+		{"DEBUG", SQL_PARAMS_NONE, false, false, []string{"[DEBUG]", "token = ?"}, []string{"s3cret"}},
+		{"INFO", SQL_PARAMS_ALL, false, true, []string{"[ERROR]", "token = \"s3cret\""}, nil},
+		// 级别不输出时不记录
+		{"INFO", SQL_PARAMS_ALL, false, false, nil, []string{"sql"}},
+		{"ERROR", SQL_PARAMS_ALL, true, false, nil, []string{"sql"}},
+	}
+	for i, c := range cases {
+		buf.Reset()
+		slog.SetLevel(c.level)
+		SetSQLParams(c.params)
+		SetSlowThreshold(0)
+		if c.slow {
+			SetSlowThreshold(time.Nanosecond)
+		}
+		column := "token"
+		if c.fail {
+			column = "missing"
+		}
+		var count int64
+		db.WithContext(ctx).Table("secrets").Where(column+" = ? OR token = ?", "s3cret", "s3cret").Count(&count)
+
+		out := buf.String()
+		for _, str := range c.contains {
+			if !strings.Contains(out, str) {
+				t.Errorf("case %d: expect %q in log %q", i, str, out)
+			}
+		}
+		for _, str := range c.excludes {
+			if strings.Contains(out, str) {
+				t.Errorf("case %d: unexpected %q in log %q", i, str, out)
+			}
+		}
+	}
+
+	if err := SetSQLParams("some"); err == nil {
+		t.Error("expect an error for invalid sql params mode")
+	}
+}
@@ -0,0 +1,554 @@
+package dbstarter
+
+/*
+
+数据库迁移。
+
+迁移以版本号(如20220301120000)排序执行，有两种形式：
+	- SQL文件：`<version>_<name>.up.sql`，及可选的`<version>_<name>.down.sql`，放在同一目录下，由NewMigrator(db, dir)加载。
+	- Go函数：在init()中调用RegisterMigration()注册。
+
+已执行的迁移记录在MIGRATIONS_TABLE表中，每个迁移及其记录在同一事务中执行。
+执行迁移前需获得MIGRATION_LOCK_TABLE表中的锁，多个实例同时启动时，只有一个实例执行迁移，其他实例等待后跳过已执行的迁移。
+持有锁期间定期更新持有时间，执行每个迁移前检查锁仍由本实例持有；更新失败时取消正在执行的迁移。
+
+命令行用法，见Migrator.Run()：
+
+	migrate            执行所有未执行的迁移
+	rollback [N]       回滚最近执行的N个迁移，默认为1
+	status             显示所有迁移的状态
+
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const MIGRATIONS_TABLE = "schema_migrations"
+const MIGRATION_LOCK_TABLE = "schema_migrations_lock"
+
+const DEFAULT_MIGRATION_LOCK_TIMEOUT = 5 * time.Minute // 等待锁的最长时间
+const DEFAULT_MIGRATION_LOCK_EXPIRE = 30 * time.Minute // 持有锁的实例异常退出时，锁在此时间后失效
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // 为nil表示不可回滚
+}
+
+// 迁移状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Missing   bool // 已执行，但迁移文件或函数已不存在
+}
+
+type schemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return MIGRATIONS_TABLE
+}
+
+type schemaMigrationLock struct {
+	ID       int `gorm:"primaryKey;autoIncrement:false"`
+	Locked   bool
+	LockedBy string `gorm:"size:255"`
+	LockedAt time.Time
+}
+
+func (schemaMigrationLock) TableName() string {
+	return MIGRATION_LOCK_TABLE
+}
+
+var (
+	registeredMigrations []Migration
+	registryLock         sync.Mutex
+)
+
+// 注册一个Go函数形式的迁移，一般在init()中调用。
+func RegisterMigration(migration Migration) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registeredMigrations = append(registeredMigrations, migration)
+}
+
+type Migrator struct {
+	DB          *gorm.DB
+	LockTimeout time.Duration
+	LockExpire  time.Duration
+
+	migrations []Migration
+	owner      string
+}
+
+// 创建一个Migrator，包括所有已注册的迁移，及dir目录下的SQL文件迁移。dir为空时不加载SQL文件。
+func NewMigrator(db *gorm.DB, dir string) (*Migrator, error) {
+	registryLock.Lock()
+	migrations := make([]Migration, len(registeredMigrations))
+	copy(migrations, registeredMigrations)
+	registryLock.Unlock()
+
+	if dir != "" {
+		fileMigrations, err := loadMigrationDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, fileMigrations...)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, migration := range migrations {
+		if migration.Version <= 0 {
+			return nil, fmt.Errorf("invalid migration version %d of '%s'", migration.Version, migration.Name)
+		}
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up migration", migration.Version, migration.Name)
+		}
+		if i > 0 && migrations[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migration.Version)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	return &Migrator{
+		DB:          db,
+		LockTimeout: DEFAULT_MIGRATION_LOCK_TIMEOUT,
+		LockExpire:  DEFAULT_MIGRATION_LOCK_EXPIRE,
+		migrations:  migrations,
+		owner:       fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano()),
+	}, nil
+}
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+func loadMigrationDir(dir string) ([]Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, file := range files {
+		matches := migrationFileRe.FindStringSubmatch(file.Name())
+		if file.IsDir() || matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file '%s'. %s", file.Name(), err.Error())
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration files of version %d have different names: '%s', '%s'", version, migration.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			migration.Up = sqlMigration(string(content))
+		} else {
+			migration.Down = sqlMigration(string(content))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	return migrations, nil
+}
+
+// 逐条执行SQL文件中的语句。部分驱动(如postgres)不支持一次执行多条语句。
+func sqlMigration(content string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(content, tx.Dialector.Name() == DRIVER_MYSQL) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// 以';'分隔SQL语句，忽略引号内、postgres的$$或$tag$引用内，及注释中的';'。注释不包含在结果中。
+// backslashEscape为true时(mysql)，单引号、双引号内的'\'为转义符。
+func splitStatements(content string, backslashEscape bool) []string {
+	statements := []string{}
+	current := strings.Builder{}
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	var quote rune
+	runes := []rune(content)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == '\\' && backslashEscape && quote != '`' && i+1 < len(runes) {
+				current.WriteRune(r)
+				i++
+				r = runes[i]
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '$':
+			// 原样保留$tag$...$tag$之间的内容，如函数体
+			if tag := dollarTag(runes[i:]); tag != "" {
+				tagLen := len([]rune(tag))
+				n := len(runes) - i // 未闭合时保留到结尾
+				rest := string(runes[i+tagLen:])
+				if end := strings.Index(rest, tag); end >= 0 {
+					n = tagLen*2 + len([]rune(rest[:end]))
+				}
+				current.WriteString(string(runes[i : i+n]))
+				i += n - 1
+				continue
+			}
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			// 单行注释
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			current.WriteRune('\n')
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			// 块注释
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i++
+			current.WriteRune(' ')
+			continue
+		case r == ';':
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return statements
+}
+
+// runes开头的postgres美元引用标签，如`$$`、`$body$`，不是标签时(如参数`$1`)返回空字符串
+func dollarTag(runes []rune) string {
+	for i := 1; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '$':
+			return string(runes[:i+1])
+		case r == '_' || unicode.IsLetter(r) || (i > 1 && unicode.IsDigit(r)):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// 创建锁表及锁记录。多个实例可能同时创建锁表，创建失败时若表已存在则忽略错误。
+func (m *Migrator) ensureLockTable() error {
+	if !m.DB.Migrator().HasTable(&schemaMigrationLock{}) {
+		if err := m.DB.Migrator().CreateTable(&schemaMigrationLock{}); err != nil && !m.DB.Migrator().HasTable(&schemaMigrationLock{}) {
+			return err
+		}
+	}
+	return m.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&schemaMigrationLock{ID: 1}).Error
+}
+
+// 获取迁移锁，超过LockTimeout仍未获得时返回错误。锁超过LockExpire未释放时视为失效。
+func (m *Migrator) lock() error {
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		now := time.Now()
+		result := m.DB.Model(&schemaMigrationLock{}).
+			Where("id = ? AND (locked = ? OR locked_at < ?)", 1, false, now.Add(-m.LockExpire)).
+			Updates(map[string]interface{}{"locked": true, "locked_by": m.owner, "locked_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+		if now.After(deadline) {
+			lock := schemaMigrationLock{}
+			m.DB.Take(&lock, 1)
+			return fmt.Errorf("timeout waiting for migration lock, held by '%s' since %s", lock.LockedBy, lock.LockedAt.Format(time.RFC3339))
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// 更新持有锁的时间，锁已被其他实例获得(如本实例停顿超过LockExpire)时返回错误
+func (m *Migrator) renewLock() error {
+	result := m.DB.Model(&schemaMigrationLock{}).
+		Where("id = ? AND locked = ? AND locked_by = ?", 1, true, m.owner).
+		Update("locked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("migration lock is no longer held by this instance")
+	}
+	return nil
+}
+
+// 持有锁期间，每LockExpire/3更新一次持有时间，避免执行耗时较长的迁移时锁失效。关闭stop后返回。
+// 更新失败时调用cancel取消正在执行的迁移，避免锁被其他实例获得后同时执行。
+func (m *Migrator) heartbeat(stop <-chan struct{}, cancel context.CancelFunc) {
+	interval := m.LockExpire / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := m.renewLock(); err != nil {
+				slog.Error(fmt.Sprintf("dbstarter: failed to renew migration lock, aborting migration. %s", err.Error()))
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (m *Migrator) unlock() {
+	err := m.DB.Model(&schemaMigrationLock{}).
+		Where("id = ? AND locked_by = ?", 1, m.owner).
+		Updates(map[string]interface{}{"locked": false, "locked_by": ""}).Error
+	if err != nil {
+		slog.Error(fmt.Sprintf("dbstarter: failed to release migration lock. %s", err.Error()))
+	}
+}
+
+// 持有锁时执行fn。迁移记录表在获得锁后创建，避免多个实例同时执行DDL。
+// 无法更新锁的持有时间时取消ctx，fn中的数据库操作应使用ctx。
+func (m *Migrator) withLock(fn func(ctx context.Context) error) error {
+	if err := m.ensureLockTable(); err != nil {
+		return err
+	}
+	if err := m.lock(); err != nil {
+		return err
+	}
+	defer m.unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.heartbeat(stop, cancel)
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	if err := m.DB.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	if err := fn(ctx); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("migration aborted, failed to renew migration lock. %s", err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// 已执行的迁移，迁移记录表不存在时为空
+func (m *Migrator) applied() ([]schemaMigration, error) {
+	records := []schemaMigration{}
+	if !m.DB.Migrator().HasTable(&schemaMigration{}) {
+		return records, nil
+	}
+	err := m.DB.Order("version").Find(&records).Error
+	return records, err
+}
+
+// 执行所有未执行的迁移，返回执行的数量。
+func (m *Migrator) Up() (int, error) {
+	count := 0
+	err := m.withLock(func(ctx context.Context) error {
+		// 获得锁后再读取，其他实例可能已执行了部分迁移
+		records, err := m.applied()
+		if err != nil {
+			return err
+		}
+		done := map[int64]bool{}
+		for _, record := range records {
+			done[record.Version] = true
+		}
+
+		for _, migration := range m.migrations {
+			if done[migration.Version] {
+				continue
+			}
+			if err := m.renewLock(); err != nil {
+				return err
+			}
+			slog.Info(fmt.Sprintf("dbstarter: applying migration %d_%s.", migration.Version, migration.Name))
+			err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s. %s", migration.Version, migration.Name, err.Error())
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// 回滚最近执行的n个迁移，返回回滚的数量。
+func (m *Migrator) Down(n int) (int, error) {
+	count := 0
+	err := m.withLock(func(ctx context.Context) error {
+		records, err := m.applied()
+		if err != nil {
+			return err
+		}
+		byVersion := map[int64]Migration{}
+		for _, migration := range m.migrations {
+			byVersion[migration.Version] = migration
+		}
+
+		for i := len(records) - 1; i >= 0 && count < n; i-- {
+			record := records[i]
+			migration, ok := byVersion[record.Version]
+			if !ok {
+				return fmt.Errorf("cannot rollback migration %d_%s, migration not found", record.Version, record.Name)
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("cannot rollback migration %d_%s, it is irreversible", record.Version, record.Name)
+			}
+			if err := m.renewLock(); err != nil {
+				return err
+			}
+			slog.Info(fmt.Sprintf("dbstarter: rolling back migration %d_%s.", migration.Version, migration.Name))
+			err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, record.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("failed to rollback migration %d_%s. %s", migration.Version, migration.Name, err.Error())
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// 所有迁移的状态，按版本号排序。不获取锁，也不创建任何表。
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	records, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*MigrationStatus{}
+	for _, migration := range m.migrations {
+		byVersion[migration.Version] = &MigrationStatus{Version: migration.Version, Name: migration.Name}
+	}
+	for _, record := range records {
+		status, ok := byVersion[record.Version]
+		if !ok {
+			status = &MigrationStatus{Version: record.Version, Name: record.Name, Missing: true}
+			byVersion[record.Version] = status
+		}
+		appliedAt := record.AppliedAt
+		status.Applied, status.AppliedAt = true, &appliedAt
+	}
+
+	res := make([]MigrationStatus, 0, len(byVersion))
+	for _, status := range byVersion {
+		res = append(res, *status)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// 执行迁移命令，args如：["migrate"], ["rollback", "2"], ["status"]。结果输出到标准输出。
+func (m *Migrator) Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing migration command, must be one of 'migrate', 'rollback [N]', 'status'")
+	}
+	switch args[0] {
+	case "migrate":
+		count, err := m.Up()
+		fmt.Printf("%d migration(s) applied.\n", count)
+		return err
+	case "rollback":
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n <= 0 {
+				return fmt.Errorf("invalid rollback steps '%s', must be a positive integer", args[1])
+			}
+		}
+		count, err := m.Down(n)
+		fmt.Printf("%d migration(s) rolled back.\n", count)
+		return err
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Missing {
+				state += " (missing)"
+			}
+			fmt.Printf("%d_%s\t%s\n", status.Version, status.Name, state)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migration command '%s', must be one of 'migrate', 'rollback [N]', 'status'", args[0])
+}
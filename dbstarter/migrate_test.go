@@ -0,0 +1,178 @@
+package dbstarter
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		content    string
+		mysql      bool
+		statements []string
+	}{
+		{"SELECT 1; SELECT 2;", false, []string{"SELECT 1", "SELECT 2"}},
+		{"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`)", false, []string{"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`)"}},
+		{"SELECT 'it''s;'; SELECT 2", false, []string{"SELECT 'it''s;'", "SELECT 2"}},
+		{"-- comment; here\nSELECT 1; -- trailing;\n", false, []string{"SELECT 1"}},
+		{"/* block; comment */ SELECT 1; /* multi\nline; */ SELECT /* ; */ 2", false, []string{"SELECT 1", "SELECT   2"}},
+		{"SELECT 1 /* unclosed; comment", false, []string{"SELECT 1"}},
+		{
+			"CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN NEW.a := 1; RETURN NEW; END; $$ LANGUAGE plpgsql; SELECT 1",
+			false, []string{"CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN NEW.a := 1; RETURN NEW; END; $$ LANGUAGE plpgsql", "SELECT 1"},
+		},
+		{
+			"DO $body$ BEGIN PERFORM '$$;'; END $body$; SELECT 2",
+			false, []string{"DO $body$ BEGIN PERFORM '$$;'; END $body$", "SELECT 2"},
+		},
+		{"SELECT $1, $2; SELECT 3", false, []string{"SELECT $1, $2", "SELECT 3"}},
+		{"SELECT $$ unclosed; body", false, []string{"SELECT $$ unclosed; body"}},
+		{"  ;; \n", false, []string{}},
+		// mysql引号内的'\'为转义符
+		{`INSERT INTO t VALUES ('it\'s; fine', "a\"; b"); SELECT 2`, true, []string{`INSERT INTO t VALUES ('it\'s; fine', "a\"; b")`, "SELECT 2"}},
+		{`SELECT 'a\\'; SELECT 2`, true, []string{`SELECT 'a\\'`, "SELECT 2"}},
+		{"SELECT `a\\`; SELECT 2", true, []string{"SELECT `a\\`", "SELECT 2"}},
+		{`SELECT 'a\'; SELECT 2`, false, []string{`SELECT 'a\'`, "SELECT 2"}},
+	}
+	for _, c := range cases {
+		if statements := splitStatements(c.content, c.mysql); !reflect.DeepEqual(statements, c.statements) {
+			t.Errorf("splitStatements(%q) = %q, want %q", c.content, statements, c.statements)
+		}
+	}
+}
+
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestMigrator(t *testing.T) {
+	_, db := newTestDB(t)
+	dir := writeMigrations(t, map[string]string{
+		"1_users.up.sql":        "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); /* seed; */ INSERT INTO users (name) VALUES ('a;b');",
+		"1_users.down.sql":      "DROP TABLE users;",
+		"2_index.up.sql":        "-- index\nCREATE INDEX idx_users_name ON users (name);",
+		"2_index.down.sql":      "DROP INDEX idx_users_name;",
+		"3_irreversible.up.sql": "ALTER TABLE users ADD COLUMN age INTEGER;",
+		"README.md":             "not a migration",
+	})
+	m, err := NewMigrator(db, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未执行迁移前，Status不创建任何表
+	statuses, err := m.Status()
+	if err != nil || len(statuses) != 3 || statuses[0].Applied {
+		t.Fatalf("unexpected status %+v, %v", statuses, err)
+	}
+	if db.Migrator().HasTable(MIGRATIONS_TABLE) || db.Migrator().HasTable(MIGRATION_LOCK_TABLE) {
+		t.Error("Status should not create tables")
+	}
+
+	if count, err := m.Up(); err != nil || count != 3 {
+		t.Fatalf("expect 3 migrations applied, got %d, %v", count, err)
+	}
+	if count, err := m.Up(); err != nil || count != 0 {
+		t.Fatalf("expect no migration applied, got %d, %v", count, err)
+	}
+	var name string
+	db.Raw("SELECT name FROM users").Scan(&name)
+	if name != "a;b" {
+		t.Errorf("unexpected seed data %q", name)
+	}
+
+	if _, err := m.Down(1); err == nil {
+		t.Error("expect an error rolling back irreversible migration")
+	}
+	db.Exec("DELETE FROM schema_migrations WHERE version = 3")
+	if count, err := m.Down(2); err != nil || count != 2 {
+		t.Fatalf("expect 2 migrations rolled back, got %d, %v", count, err)
+	}
+	if db.Migrator().HasTable("users") {
+		t.Error("table users should be dropped")
+	}
+	statuses, err = m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Errorf("migration %d should not be applied", status.Version)
+		}
+	}
+
+	// 锁已释放
+	lock := schemaMigrationLock{}
+	db.Take(&lock, 1)
+	if lock.Locked || lock.LockedBy != "" {
+		t.Errorf("lock should be released, got %+v", lock)
+	}
+}
+
+func TestMigrationLockHeartbeat(t *testing.T) {
+	_, db := newTestDB(t)
+	m, err := NewMigrator(db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.LockExpire = 300 * time.Millisecond
+	other, _ := NewMigrator(db, "")
+	other.LockTimeout = 0
+	other.LockExpire = m.LockExpire
+
+	err = m.withLock(func(ctx context.Context) error {
+		// 超过LockExpire后，锁仍由m持有
+		time.Sleep(2 * m.LockExpire)
+		if err := other.lock(); err == nil {
+			t.Error("expect the lock to be renewed")
+		}
+		return m.renewLock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer other.unlock()
+	if err := m.renewLock(); err == nil {
+		t.Error("expect an error renewing a lock held by another instance")
+	}
+}
+
+func TestMigrationLockLost(t *testing.T) {
+	_, db := newTestDB(t)
+	m, err := NewMigrator(db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.LockExpire = 150 * time.Millisecond
+
+	err = m.withLock(func(ctx context.Context) error {
+		// 锁被其他实例获得后，取消正在执行的迁移
+		db.Model(&schemaMigrationLock{}).Where("id = ?", 1).Update("locked_by", "other")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+			t.Error("expect the context to be canceled")
+			return nil
+		}
+	})
+	if err == nil || !strings.Contains(err.Error(), "renew migration lock") {
+		t.Errorf("expect a lock lost error, got %v", err)
+	}
+}
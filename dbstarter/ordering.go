@@ -0,0 +1,68 @@
+package dbstarter
+
+import (
+	"fmt"
+	"strings"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 解析ordering参数，如`-created_at,name`，以`-`开头表示降序。字段必须在Serializor.OrderableFields中声明。
+func (slz *Serializor) parseOrdering(ordering string) ([]clause.OrderByColumn, error) {
+	columns := []clause.OrderByColumn{}
+	for _, item := range strings.Split(ordering, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		field, desc := item, false
+		if strings.HasPrefix(field, "-") {
+			field, desc = field[1:], true
+		} else if strings.HasPrefix(field, "+") {
+			field = field[1:]
+		}
+		if !tools.IsStrInSlice(field, slz.OrderableFields) {
+			return nil, &QueryError{Key: "ordering", Msg: fmt.Sprintf("ordering by '%s' is not allowed", field)}
+		}
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: field}, Desc: desc})
+	}
+	return columns, nil
+}
+
+// 排序。提供了ordering参数时按其排序，否则使用Serializor.OrderBy。
+func (slz *Serializor) order(dbtx *gorm.DB, ordering string) (*gorm.DB, error) {
+	if ordering == "" {
+		if slz.OrderBy != "" {
+			dbtx = dbtx.Order(slz.OrderBy)
+		}
+		return dbtx, nil
+	}
+	columns, err := slz.parseOrdering(ordering)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		dbtx = dbtx.Order(column)
+	}
+	return dbtx, nil
+}
+
+// 排序字段。提供了ordering参数时按其解析，否则解析Serializor.OrderBy，如`created_at desc, id`。
+func (slz *Serializor) orderColumns(ordering string) ([]clause.OrderByColumn, error) {
+	if ordering != "" {
+		return slz.parseOrdering(ordering)
+	}
+	columns := []clause.OrderByColumn{}
+	for _, item := range strings.Split(slz.OrderBy, ",") {
+		parts := strings.Fields(item)
+		if len(parts) == 0 {
+			continue
+		}
+		desc := len(parts) > 1 && strings.EqualFold(parts[1], "desc")
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: parts[0]}, Desc: desc})
+	}
+	return columns, nil
+}
@@ -0,0 +1,79 @@
+package dbstarter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm/clause"
+)
+
+func TestParseOrdering(t *testing.T) {
+	slz := &Serializor{OrderableFields: []string{"name", "age", "created_at"}}
+	col := func(name string, desc bool) clause.OrderByColumn {
+		return clause.OrderByColumn{Column: clause.Column{Name: name}, Desc: desc}
+	}
+
+	cases := []struct {
+		ordering string
+		columns  []clause.OrderByColumn
+		valid    bool
+	}{
+		{"", []clause.OrderByColumn{}, true},
+		{"name", []clause.OrderByColumn{col("name", false)}, true},
+		{"-created_at,name", []clause.OrderByColumn{col("created_at", true), col("name", false)}, true},
+		{" +age , -name ,", []clause.OrderByColumn{col("age", false), col("name", true)}, true},
+		{"id", nil, false},
+		{"name,-password", nil, false},
+		{"name desc", nil, false},
+		{"name; DROP TABLE users", nil, false},
+	}
+	for _, c := range cases {
+		columns, err := slz.parseOrdering(c.ordering)
+		var queryErr *QueryError
+		if !c.valid {
+			if !errors.As(err, &queryErr) || queryErr.Key != "ordering" {
+				t.Errorf("%q: expect a QueryError, got %v", c.ordering, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", c.ordering, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(columns, c.columns) {
+			t.Errorf("%q: expect %v, got %v", c.ordering, c.columns, columns)
+		}
+	}
+}
+
+func TestListQueryOrdering(t *testing.T) {
+	conn, _ := newTestDB(t, testUsersDDL, testUsersData,
+		"INSERT INTO users (id, name, age) VALUES (6, 'Tom', 60)")
+	slz := &Serializor{Conn: conn, OrderableFields: []string{"name", "age"}, OrderBy: "age desc"}
+
+	cases := []struct {
+		query map[string]interface{}
+		ids   []int
+	}{
+		{map[string]interface{}{}, []int{6, 5, 4, 3, 2, 1}},
+		{map[string]interface{}{"ordering": "age"}, []int{1, 2, 3, 4, 5, 6}},
+		{map[string]interface{}{"ordering": "name,-age"}, []int{3, 6, 1, 4, 5, 2}},
+		{map[string]interface{}{"ordering": "-name,age"}, []int{2, 5, 4, 1, 6, 3}},
+	}
+	for _, c := range cases {
+		ids, err := listIDs(t, slz, "users", c.query)
+		if err != nil {
+			t.Errorf("%v: %s", c.query, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(ids, c.ids) {
+			t.Errorf("%v: expect %v, got %v", c.query, c.ids, ids)
+		}
+	}
+
+	// 未声明FilterFields时，任何过滤都被拒绝
+	if _, err := listIDs(t, slz, "users", map[string]interface{}{"age": 18}); err == nil {
+		t.Error("expect filtering to be rejected without FilterFields")
+	}
+}
@@ -0,0 +1,87 @@
+package dbstarter
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 默认连接的名称，对应全局变量DB
+const DEFAULT_CONN_NAME = "default"
+
+var (
+	conns    = map[string]*cluster{}
+	connLock sync.RWMutex
+)
+
+// 注册一个命名的数据库连接，同名连接将被替换。
+func Register(name string, db *gorm.DB) {
+	registerCluster(name, &cluster{primary: db})
+}
+
+// 替换同名连接时，关闭原连接的所有连接池，仍被其他名称使用的连接池除外。
+func registerCluster(name string, c *cluster) {
+	connLock.Lock()
+	old := conns[name]
+	conns[name] = c
+	var inUse map[*sql.DB]bool
+	if old != nil {
+		inUse = map[*sql.DB]bool{}
+		for _, item := range conns {
+			for _, db := range item.all() {
+				if sqlDB, err := db.DB(); err == nil {
+					inUse[sqlDB] = true
+				}
+			}
+		}
+	}
+	connLock.Unlock()
+
+	if old != nil {
+		old.close(inUse)
+	}
+}
+
+func getCluster(name string) (*cluster, error) {
+	connLock.RLock()
+	defer connLock.RUnlock()
+	c, ok := conns[name]
+	if !ok || c.primary == nil {
+		return nil, fmt.Errorf("database '%s' is not initialized", name)
+	}
+	return c, nil
+}
+
+// 根据名称获取数据库连接(主库)，用于写操作及事务。
+func Get(name string) (*gorm.DB, error) {
+	c, err := getCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.primary, nil
+}
+
+// 根据名称获取用于读操作的数据库连接。
+// 配置了副本时，按replica_policy选择一个健康的副本；没有可用副本时，返回主库。
+func GetReader(name string) (*gorm.DB, error) {
+	c, err := getCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.reader(), nil
+}
+
+// 所有已注册的数据库连接名称
+func Names() []string {
+	connLock.RLock()
+	defer connLock.RUnlock()
+	names := make([]string, 0, len(conns))
+	for name := range conns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
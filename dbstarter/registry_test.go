@@ -0,0 +1,35 @@
+package dbstarter
+
+import (
+	"testing"
+)
+
+func TestRegisterClosesReplacedPool(t *testing.T) {
+	open := func() *cluster {
+		db, err := Open(ConnConfig{Driver: DRIVER_SQLITE, Name: ":memory:"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &cluster{primary: db}
+	}
+	isClosed := func(c *cluster) bool {
+		sqlDB, _ := c.primary.DB()
+		return sqlDB.Ping() != nil
+	}
+
+	first, second := open(), open()
+	registerCluster("test_registry", first)
+	registerCluster("test_registry_alias", &cluster{primary: first.primary})
+	registerCluster("test_registry", second)
+	if isClosed(first) {
+		t.Error("pool still registered by another name must not be closed")
+	}
+
+	registerCluster("test_registry_alias", open())
+	if !isClosed(first) {
+		t.Error("replaced pool should be closed")
+	}
+	if isClosed(second) {
+		t.Error("current pool should not be closed")
+	}
+}
@@ -0,0 +1,227 @@
+package dbstarter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+
+	"gorm.io/gorm"
+)
+
+const (
+	POLICY_ROUND_ROBIN = "round_robin"
+	POLICY_LEAST_CONN  = "least_conn"
+)
+
+const DEFAULT_HEALTH_CHECK_INTERVAL = 10 * time.Second
+const DEFAULT_MAX_FAILURES = 3
+
+// 只读副本的配置。User、Password为空时与主库相同，库名、字符集等其他配置均与主库相同。
+type ReplicaConfig struct {
+	Host     string `config:"host,required"`
+	Port     int    `config:"port"`
+	User     string `config:"user"`
+	Password string `config:"password"`
+}
+
+type replica struct {
+	addr     string
+	db       *gorm.DB
+	healthy  int32 // 1表示健康，需原子读写
+	failures int   // 连续ping失败次数，仅在健康检查协程中读写
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// 一个主库及其只读副本
+type cluster struct {
+	primary  *gorm.DB
+	replicas []*replica
+	policy   string
+	next     uint64 // round_robin计数
+
+	interval    time.Duration
+	maxFailures int
+	stopOnce    sync.Once
+	stop        chan struct{}
+}
+
+// 选择一个健康的副本用于读操作。没有可用副本时，返回主库。
+func (c *cluster) reader() *gorm.DB {
+	healthy := make([]*replica, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		if r.isHealthy() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return c.primary
+	}
+
+	if c.policy == POLICY_LEAST_CONN {
+		var chosen *replica
+		minInUse := -1
+		for _, r := range healthy {
+			sqlDB, err := r.db.DB()
+			if err != nil {
+				continue
+			}
+			if inUse := sqlDB.Stats().InUse; minInUse < 0 || inUse < minInUse {
+				chosen, minInUse = r, inUse
+			}
+		}
+		if chosen != nil {
+			return chosen.db
+		}
+	}
+
+	// round_robin
+	i := atomic.AddUint64(&c.next, 1)
+	return healthy[int(i%uint64(len(healthy)))].db
+}
+
+// 定时ping所有副本。连续失败maxFailures次后剔除，ping成功后重新启用。
+func (c *cluster) healthCheck(name string) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, r := range c.replicas {
+			err := ping(r.db, c.interval)
+			if err == nil {
+				r.failures = 0
+				if atomic.CompareAndSwapInt32(&r.healthy, 0, 1) {
+					slog.Info(fmt.Sprintf("dbstarter: replica '%s' of database '%s' recovered, re-admitted.", r.addr, name))
+				}
+				continue
+			}
+			r.failures++
+			if r.failures >= c.maxFailures && atomic.CompareAndSwapInt32(&r.healthy, 1, 0) {
+				slog.Warning(fmt.Sprintf("dbstarter: replica '%s' of database '%s' ejected after %d failed pings. %s", r.addr, name, r.failures, err.Error()))
+			}
+		}
+	}
+}
+
+// 主库及所有副本
+func (c *cluster) all() []*gorm.DB {
+	dbs := []*gorm.DB{c.primary}
+	for _, r := range c.replicas {
+		dbs = append(dbs, r.db)
+	}
+	return dbs
+}
+
+// 主库及所有副本，以Stats()中的名称为key
+func (c *cluster) pools(name string) map[string]*gorm.DB {
+	res := map[string]*gorm.DB{name: c.primary}
+	for _, r := range c.replicas {
+		res[name+"@"+r.addr] = r.db
+	}
+	return res
+}
+
+// 停止健康检查，并关闭主库及所有副本的连接池。inUse中的连接池仍被使用，不关闭。
+func (c *cluster) close(inUse map[*sql.DB]bool) {
+	c.stopOnce.Do(func() {
+		if c.stop != nil {
+			close(c.stop)
+		}
+		for _, db := range c.all() {
+			closeDB(db, inUse)
+		}
+	})
+}
+
+func closeDB(db *gorm.DB, inUse map[*sql.DB]bool) {
+	if db == nil {
+		return
+	}
+	sqlDB, err := db.DB()
+	if err != nil || inUse[sqlDB] {
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		slog.Warning(fmt.Sprintf("dbstarter: failed to close db pool. %s", err.Error()))
+	}
+}
+
+func ping(db *gorm.DB, timeout time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// 打开主库及其所有副本，并以name注册。副本不可用时不影响初始化，将在恢复后自动启用。
+func openCluster(name string, conf ConnConfig) (*gorm.DB, error) {
+	primary, err := Open(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cluster{
+		primary:     primary,
+		policy:      conf.ReplicaPolicy,
+		interval:    conf.HealthCheckInterval,
+		maxFailures: conf.MaxFailures,
+	}
+	if c.interval <= 0 {
+		c.interval = DEFAULT_HEALTH_CHECK_INTERVAL
+	}
+	if c.maxFailures <= 0 {
+		c.maxFailures = DEFAULT_MAX_FAILURES
+	}
+
+	for _, replicaConf := range conf.Replicas {
+		rconf := conf.replicaConn(replicaConf)
+		db, err := open(rconf, true)
+		if err != nil {
+			c.close(nil) // 关闭已打开的主库及副本
+			return nil, fmt.Errorf("failed to open replica '%s:%d'. %s", rconf.Host, rconf.Port, err.Error())
+		}
+		r := &replica{addr: fmt.Sprintf("%s:%d", rconf.Host, rconf.Port), db: db}
+		if err := ping(db, c.interval); err != nil {
+			slog.Warning(fmt.Sprintf("dbstarter: replica '%s' of database '%s' is unavailable. %s", r.addr, name, err.Error()))
+		} else {
+			r.healthy = 1
+		}
+		c.replicas = append(c.replicas, r)
+	}
+
+	if len(c.replicas) > 0 {
+		c.stop = make(chan struct{})
+		go c.healthCheck(name)
+	}
+	registerCluster(name, c)
+	return primary, nil
+}
+
+// 生成副本的连接配置
+func (conf ConnConfig) replicaConn(replicaConf ReplicaConfig) ConnConfig {
+	rconf := conf
+	rconf.Replicas = nil
+	rconf.Host = replicaConf.Host
+	rconf.Port = replicaConf.Port
+	if replicaConf.User != "" {
+		rconf.User = replicaConf.User
+	}
+	if replicaConf.Password != "" {
+		rconf.Password = replicaConf.Password
+	}
+	return rconf
+}
@@ -0,0 +1,143 @@
+package dbstarter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 打开一个sqlite文件数据库作为副本。readonly为true时以只读模式打开，文件被移走后ping失败。
+func openReplica(t *testing.T, path string, readonly bool) *replica {
+	t.Helper()
+	name := path
+	if readonly {
+		name = "file:" + path + "?mode=ro"
+	}
+	db, err := open(ConnConfig{Driver: DRIVER_SQLITE, Name: name}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不保留空闲连接，每次ping都重新打开文件
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxIdleConns(0)
+	t.Cleanup(func() { closeDB(db, nil) })
+	return &replica{addr: filepath.Base(path), db: db, healthy: 1}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	dir := t.TempDir()
+	primary, err := Open(ConnConfig{Driver: DRIVER_SQLITE, Name: filepath.Join(dir, "primary.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(primary, nil)
+	a, b := openReplica(t, filepath.Join(dir, "a.db"), false), openReplica(t, filepath.Join(dir, "b.db"), false)
+	c := &cluster{primary: primary, replicas: []*replica{a, b}, policy: POLICY_ROUND_ROBIN}
+
+	count := func() map[*gorm.DB]int {
+		counts := map[*gorm.DB]int{}
+		for i := 0; i < 10; i++ {
+			counts[c.reader()]++
+		}
+		return counts
+	}
+
+	// round_robin平均分配
+	if counts := count(); counts[a.db] != 5 || counts[b.db] != 5 {
+		t.Errorf("expect reads evenly distributed, got a=%d b=%d primary=%d", counts[a.db], counts[b.db], counts[primary])
+	}
+
+	// least_conn选择使用中连接最少的副本
+	c.policy = POLICY_LEAST_CONN
+	sqlDB, _ := a.db.DB()
+	sqlDB.SetMaxIdleConns(1)
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := count(); counts[b.db] != 10 {
+		t.Errorf("expect all reads on the idle replica, got a=%d b=%d", counts[a.db], counts[b.db])
+	}
+	conn.Close()
+
+	// 跳过不健康的副本
+	c.policy = POLICY_ROUND_ROBIN
+	atomic.StoreInt32(&a.healthy, 0)
+	if counts := count(); counts[b.db] != 10 {
+		t.Errorf("expect all reads on the healthy replica, got a=%d b=%d", counts[a.db], counts[b.db])
+	}
+
+	// 全部不可用时读主库
+	atomic.StoreInt32(&b.healthy, 0)
+	if counts := count(); counts[primary] != 10 {
+		t.Errorf("expect all reads on primary, got %v", counts)
+	}
+}
+
+func TestReplicaHealthCheck(t *testing.T) {
+	dir := t.TempDir()
+	primary, err := Open(ConnConfig{Driver: DRIVER_SQLITE, Name: filepath.Join(dir, "primary.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(primary, nil)
+	a := openReplica(t, filepath.Join(dir, "a.db"), false)
+	path := filepath.Join(dir, "b.db")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := openReplica(t, path, true)
+	c := &cluster{
+		primary:     primary,
+		replicas:    []*replica{a, b},
+		policy:      POLICY_ROUND_ROBIN,
+		interval:    10 * time.Millisecond,
+		maxFailures: 2,
+		stop:        make(chan struct{}),
+	}
+	go c.healthCheck("test")
+	defer c.close(nil)
+
+	waitHealthy := func(r *replica, healthy bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for r.isHealthy() != healthy {
+			if time.Now().After(deadline) {
+				t.Fatalf("replica %s: expect healthy=%v", r.addr, healthy)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// 移走b的文件，连续失败后被剔除
+	if err := os.Rename(path, path+".bak"); err != nil {
+		t.Fatal(err)
+	}
+	waitHealthy(b, false)
+	if !a.isHealthy() {
+		t.Error("replica a should stay healthy")
+	}
+	for i := 0; i < 4; i++ {
+		if db := c.reader(); db != a.db {
+			t.Fatal("expect reads on replica a after b is ejected")
+		}
+	}
+
+	// 恢复后重新启用
+	if err := os.Rename(path+".bak", path); err != nil {
+		t.Fatal(err)
+	}
+	waitHealthy(b, true)
+	counts := map[*gorm.DB]int{}
+	for i := 0; i < 4; i++ {
+		counts[c.reader()]++
+	}
+	if counts[a.db] != 2 || counts[b.db] != 2 {
+		t.Errorf("expect reads on both replicas after recovery, got a=%d b=%d", counts[a.db], counts[b.db])
+	}
+}
@@ -0,0 +1,144 @@
+package dbstarter
+
+/*
+
+ListQuery的搜索策略，通过Serializor.SearchStrategy选择，默认为SearchLike。
+
+	SearchLike              每个词须在任一SearchFields中出现，`field LIKE %词%`，无法使用索引
+	SearchPrefix            每个词须为任一SearchFields的前缀，`field LIKE 词%`，可使用索引
+	SearchFulltext          mysql全文索引，自然语言模式。SearchFields须为同一个FULLTEXT索引的字段
+	SearchFulltextBoolean   mysql全文索引，布尔模式。每个词都必须出现，且按前缀匹配
+	SearchTsvector          postgres全文搜索，使用simple配置。其他配置使用TsvectorSearch{Config: "english"}
+
+搜索内容按空白字符分词。LIKE中的`%`、`_`，以及布尔模式中的操作符，均被转义或移除，按字面匹配。
+
+*/
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// 搜索策略。返回nil表示不添加搜索条件。
+type SearchStrategy interface {
+	Condition(fields []string, search string) clause.Expression
+}
+
+var (
+	SearchLike            SearchStrategy = LikeSearch{}
+	SearchPrefix          SearchStrategy = LikeSearch{Prefix: true}
+	SearchFulltext        SearchStrategy = FulltextSearch{}
+	SearchFulltextBoolean SearchStrategy = FulltextSearch{Boolean: true}
+	SearchTsvector        SearchStrategy = TsvectorSearch{Config: "simple"}
+)
+
+func columns(fields []string) []interface{} {
+	cols := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		cols = append(cols, clause.Column{Name: field})
+	}
+	return cols
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+type LikeSearch struct {
+	Prefix bool // 为true时仅做前缀匹配
+}
+
+func (s LikeSearch) Condition(fields []string, search string) clause.Expression {
+	terms := strings.Fields(search)
+	if len(terms) == 0 || len(fields) == 0 {
+		return nil
+	}
+	ands := make([]clause.Expression, 0, len(terms))
+	for _, term := range terms {
+		pattern := EscapeLike(term) + "%"
+		if !s.Prefix {
+			pattern = "%" + pattern
+		}
+		ors := make([]clause.Expression, 0, len(fields))
+		for _, field := range fields {
+			ors = append(ors, clause.Expr{
+				SQL:  fmt.Sprintf("? LIKE ? ESCAPE '%c'", LIKE_ESCAPE),
+				Vars: []interface{}{clause.Column{Name: field}, pattern},
+			})
+		}
+		// 单个条件的OrConditions在Where中会以OR连接，这里直接使用该条件
+		if len(ors) == 1 {
+			ands = append(ands, ors[0])
+		} else {
+			ands = append(ands, clause.Or(ors...))
+		}
+	}
+	return clause.And(ands...)
+}
+
+// mysql布尔模式中的操作符
+const fulltextOperators = `+-<>()~*"@`
+
+type FulltextSearch struct {
+	Boolean bool // 为true时使用布尔模式，否则为自然语言模式
+}
+
+func (s FulltextSearch) Condition(fields []string, search string) clause.Expression {
+	if len(fields) == 0 {
+		return nil
+	}
+	mode := "NATURAL LANGUAGE"
+	if s.Boolean {
+		mode = "BOOLEAN"
+		terms := []string{}
+		for _, term := range strings.Fields(search) {
+			term = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(fulltextOperators, r) {
+					return -1
+				}
+				return r
+			}, term)
+			if term != "" {
+				terms = append(terms, "+"+term+"*")
+			}
+		}
+		search = strings.Join(terms, " ")
+	} else {
+		search = strings.Join(strings.Fields(search), " ")
+	}
+	if search == "" {
+		return nil
+	}
+	return clause.Expr{
+		SQL:  fmt.Sprintf("MATCH(%s) AGAINST(? IN %s MODE)", placeholders(len(fields)), mode),
+		Vars: append(columns(fields), search),
+	}
+}
+
+type TsvectorSearch struct {
+	Config string // 文本搜索配置，如simple、english
+}
+
+func (s TsvectorSearch) Condition(fields []string, search string) clause.Expression {
+	search = strings.Join(strings.Fields(search), " ")
+	if search == "" || len(fields) == 0 {
+		return nil
+	}
+	config := s.Config
+	if config == "" {
+		config = "simple"
+	}
+	docs := make([]string, 0, len(fields))
+	for range fields {
+		docs = append(docs, "coalesce(?, '')")
+	}
+	// plainto_tsquery将搜索内容视为普通文本，各词之间为AND
+	vars := append([]interface{}{config}, columns(fields)...)
+	vars = append(vars, config, search)
+	return clause.Expr{
+		SQL:  fmt.Sprintf("to_tsvector(?::regconfig, %s) @@ plainto_tsquery(?::regconfig, ?)", strings.Join(docs, " || ' ' || ")),
+		Vars: vars,
+	}
+}
@@ -0,0 +1,92 @@
+package dbstarter
+
+import (
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm/clause"
+)
+
+func TestEscapeLike(t *testing.T) {
+	cases := map[string]string{
+		"abc":     "abc",
+		"50%":     "50!%",
+		"a_b":     "a!_b",
+		"wow!":    "wow!!",
+		"!%_":     "!!!%!_",
+		`C:\path`: `C:\path`,
+	}
+	for str, want := range cases {
+		if got := EscapeLike(str); got != want {
+			t.Errorf("EscapeLike(%q) = %q, want %q", str, got, want)
+		}
+	}
+}
+
+func TestLikeSearch(t *testing.T) {
+	conn, _ := newTestDB(t, testUsersDDL, testUsersData)
+
+	cases := []struct {
+		strategy SearchStrategy
+		search   string
+		ids      []int
+	}{
+		{SearchLike, "", []int{1, 2, 3, 4, 5}},
+		{SearchLike, "om", []int{1, 2}},
+		{SearchLike, "OM off", []int{2}},
+		{SearchLike, "o w", []int{5}},
+		{SearchLike, "%", []int{2, 4}},
+		{SearchLike, "_", []int{3}},
+		{SearchLike, "!", []int{5}},
+		{SearchLike, "0%", []int{2, 4}},
+		{SearchLike, "x%", []int{}},
+		{SearchPrefix, "to", []int{1, 2}},
+		{SearchPrefix, "om", []int{}},
+		{SearchPrefix, "50%", []int{2}},
+		{SearchPrefix, "%", []int{}},
+		{SearchPrefix, "a_", []int{3}},
+		{SearchPrefix, "ab", []int{}},
+		{nil, "_", []int{3}}, // 默认为SearchLike
+	}
+	for _, c := range cases {
+		slz := &Serializor{Conn: conn, SearchFields: []string{"name", "note"}, SearchStrategy: c.strategy, OrderBy: "id"}
+		ids, err := listIDs(t, slz, "users", map[string]interface{}{"search": c.search})
+		if err != nil {
+			t.Errorf("%v %q: %s", c.strategy, c.search, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(ids, c.ids) {
+			t.Errorf("%v %q: expect %v, got %v", c.strategy, c.search, c.ids, ids)
+		}
+	}
+}
+
+func TestFulltextSearch(t *testing.T) {
+	fields := []string{"title", "body"}
+	title, body := clause.Column{Name: "title"}, clause.Column{Name: "body"}
+	cases := []struct {
+		strategy SearchStrategy
+		search   string
+		sql      string
+		vars     []interface{}
+	}{
+		{SearchFulltext, "  hello   world ", "MATCH(?,?) AGAINST(? IN NATURAL LANGUAGE MODE)", []interface{}{title, body, "hello world"}},
+		{SearchFulltextBoolean, `+go -"java" (x)`, "MATCH(?,?) AGAINST(? IN BOOLEAN MODE)", []interface{}{title, body, "+go* +java* +x*"}},
+		{SearchFulltextBoolean, `+ - ~`, "", nil},
+		{SearchTsvector, "a  b", "to_tsvector(?::regconfig, coalesce(?, '') || ' ' || coalesce(?, '')) @@ plainto_tsquery(?::regconfig, ?)", []interface{}{"simple", title, body, "simple", "a b"}},
+		{TsvectorSearch{}, " ", "", nil},
+	}
+	for _, c := range cases {
+		expr := c.strategy.Condition(fields, c.search)
+		if c.sql == "" {
+			if expr != nil {
+				t.Errorf("%v %q: expect no condition, got %v", c.strategy, c.search, expr)
+			}
+			continue
+		}
+		e, ok := expr.(clause.Expr)
+		if !ok || e.SQL != c.sql || !reflect.DeepEqual(e.Vars, c.vars) {
+			t.Errorf("%v %q: unexpected condition %v", c.strategy, c.search, expr)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package dbstarter
 
 import (
-	"errors"
+	"context"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/validator"
 
 	"gorm.io/gorm"
 )
@@ -14,10 +16,18 @@ type QueryData struct {
 	PageIndex  int
 	PageSize   int
 	Search     string
-	Filter     map[string]interface{}
+	Ordering   string // 如`-created_at,name`，以`-`开头表示降序
+	Cursor     string // CursorQuery专用，上一次查询返回的next或prev游标，空表示第一页
+	SkipCount  bool   // CursorQuery专用，为true时不统计总数
+
+	IncludeDeleted bool // Serializor.SoftDelete为true时，是否包括已软删除的记录
+
+	GroupBy string // AggregateQuery专用，如`status,created_at__day`
+	Agg     string // AggregateQuery专用，如`count,sum:amount`
+	Filter  map[string]interface{}
 }
 
-// 将map转化为QueryData
+// 将map转化为QueryData。分页参数可以是字符串，如来自URL查询参数时。
 func (query *QueryData) Init(data map[string]interface{}) {
 	if query.Filter == nil {
 		query.Filter = map[string]interface{}{}
@@ -25,13 +35,25 @@ func (query *QueryData) Init(data map[string]interface{}) {
 	for key, valI := range data {
 		switch key {
 		case "pagination":
-			query.Pagination, _ = valI.(bool)
+			query.Pagination, _ = toBool(valI)
 		case "page_index":
-			query.PageIndex, _ = valI.(int)
+			query.PageIndex, _ = toInt(valI)
 		case "page_size":
-			query.PageSize, _ = valI.(int)
+			query.PageSize, _ = toInt(valI)
 		case "search":
 			query.Search, _ = valI.(string)
+		case "ordering":
+			query.Ordering, _ = valI.(string)
+		case "cursor":
+			query.Cursor, _ = valI.(string)
+		case "skip_count":
+			query.SkipCount, _ = toBool(valI)
+		case "include_deleted":
+			query.IncludeDeleted, _ = toBool(valI)
+		case "group_by":
+			query.GroupBy, _ = valI.(string)
+		case "agg":
+			query.Agg, _ = valI.(string)
 		default:
 			query.Filter[key] = valI
 		}
@@ -39,54 +61,108 @@ func (query *QueryData) Init(data map[string]interface{}) {
 }
 
 type Serializor struct {
-	ListFields   []string // 空表示全部字段
-	DetailFields []string // 空表示全部字段
-	SearchFields []string // 空表示不支持按字段模糊搜索
-	FilterFields []string
-	OrderBy      string
-	Query        *QueryData
+	ListFields      []string                 // 空表示全部字段
+	DetailFields    []string                 // 空表示全部字段
+	SearchFields    []string                 // 空表示不支持按字段模糊搜索
+	SearchStrategy  SearchStrategy           // 搜索策略，nil表示SearchLike，参见search.go
+	FilterFields    []string                 // 允许过滤的字段，支持操作符(如`age__gte`)，参见filter.go。空表示不支持过滤
+	OrderBy         string                   // 默认排序，未提供ordering参数时使用
+	OrderableFields []string                 // 允许通过ordering参数排序的字段，空表示不支持ordering参数
+	GroupableFields []string                 // AggregateQuery允许分组的字段，参见aggregate.go
+	AggregateFields []string                 // AggregateQuery允许sum、avg等聚合的字段
+	WritableFields  []string                 // Create、Update允许写入的字段，空表示不支持写入
+	Validator       *validator.DataValidator // Create、Update时校验写入数据，部分更新时忽略required约束
+	SoftDelete      bool                     // 为true时，查询排除SOFT_DELETE_FIELD不为NULL的记录，资源接口的删除操作使用软删除
+	AuditColumns    []string                 // 写操作时自动维护的审计字段，可选值见GetAuditColumns()
+	User            interface{}              // 当前操作用户，写入created_by、updated_by。每个请求应使用Serializor的拷贝设置
+	Query           *QueryData
+	Conn            string          // 数据库连接名称，空表示默认连接。读操作优先使用该连接的只读副本。
+	Ctx             context.Context // 执行SQL语句的context，如当前请求的gin.Context，用于取消查询及在SQL日志中记录请求ID
 }
 
-func (slz *Serializor) ListQuery(table string, queryData map[string]interface{}) (int64, *gorm.DB, error) {
-	// 检查数据库连接状态
-	if DB == nil {
-		return 0, nil, errors.New("db is not initialized")
+// 设置Ctx后，SQL语句在Ctx中执行
+func (slz *Serializor) withContext(db *gorm.DB) *gorm.DB {
+	if slz.Ctx == nil {
+		return db
 	}
+	return db.WithContext(slz.Ctx)
+}
 
-	// 解析查询数据
+// 获取用于读操作的数据库连接
+func (slz *Serializor) reader() (*gorm.DB, error) {
+	name := slz.Conn
+	if name == "" {
+		name = DEFAULT_CONN_NAME
+	}
+	db, err := GetReader(name)
+	if err != nil && name == DEFAULT_CONN_NAME && DB != nil {
+		return slz.withContext(DB), nil // 兼容直接设置全局变量DB的用法
+	}
+	if err != nil {
+		return nil, err
+	}
+	return slz.withContext(db), nil
+}
+
+// 解析查询数据。queryData为nil时，使用Serializor.Query。
+func (slz *Serializor) parseQuery(queryData map[string]interface{}) *QueryData {
 	query := &QueryData{}
 	if queryData != nil {
 		query.Init(queryData)
 	} else if slz.Query != nil {
 		query = slz.Query
 	}
+	return query
+}
 
-	// dbtx := DB.Table(table).Debug().Where("deleted_at is NULL")
-	dbtx := DB.Table(table).Where("id > 0")
+// ListQuery、CursorQuery等共用的查询部分：软删除、过滤、搜索。不包括选择字段。
+func (slz *Serializor) baseQuery(table string, query *QueryData) (*gorm.DB, error) {
+	// 检查数据库连接状态
+	db, err := slz.reader()
+	if err != nil {
+		return nil, err
+	}
 
-	// select field
-	if len(slz.ListFields) > 0 {
-		dbtx = dbtx.Select(slz.ListFields)
+	dbtx := db.Table(table)
+	if slz.SoftDelete && !query.IncludeDeleted {
+		dbtx = dbtx.Where(notDeleted())
 	}
 
 	// filter
 	if len(query.Filter) > 0 {
-		dbtx = dbtx.Where(query.Filter)
+		if dbtx, err = slz.filter(dbtx, query.Filter); err != nil {
+			return nil, err
+		}
 	}
 
 	// search (implicitly)
 	if query.Search != "" && len(slz.SearchFields) > 0 {
-		search_str := "%" + query.Search + "%"
-		searchTx := DB.Where(slz.SearchFields[0]+" LIKE ?", search_str)
-		for _, field := range slz.SearchFields[1:] {
-			searchTx = searchTx.Or(field+" LIKE ?", search_str)
+		strategy := slz.SearchStrategy
+		if strategy == nil {
+			strategy = SearchLike
+		}
+		if expr := strategy.Condition(slz.SearchFields, query.Search); expr != nil {
+			dbtx = dbtx.Where(expr)
 		}
-		dbtx.Where(searchTx)
+	}
+	return dbtx, nil
+}
+
+func (slz *Serializor) ListQuery(table string, queryData map[string]interface{}) (int64, *gorm.DB, error) {
+	query := slz.parseQuery(queryData)
+	dbtx, err := slz.baseQuery(table, query)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// select field
+	if len(slz.ListFields) > 0 {
+		dbtx = dbtx.Select(slz.ListFields)
 	}
 
 	// order
-	if slz.OrderBy != "" {
-		dbtx = dbtx.Order(slz.OrderBy)
+	if dbtx, err = slz.order(dbtx, query.Ordering); err != nil {
+		return 0, nil, err
 	}
 
 	// totalSize by condition
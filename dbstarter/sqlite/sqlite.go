@@ -0,0 +1,17 @@
+// 注册dbstarter的sqlite driver。sqlite依赖cgo，需要时匿名导入：
+//
+//	import _ "codeops.didachuxing.com/lordaeron/go-toolbox/dbstarter/sqlite"
+package sqlite
+
+import (
+	"codeops.didachuxing.com/lordaeron/go-toolbox/dbstarter"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dbstarter.RegisterDialector(dbstarter.DRIVER_SQLITE, func(dsn string) gorm.Dialector {
+		return sqlite.Open(dsn)
+	})
+}
@@ -0,0 +1,4 @@
+package dbstarter_test
+
+// 测试使用sqlite
+import _ "codeops.didachuxing.com/lordaeron/go-toolbox/dbstarter/sqlite"
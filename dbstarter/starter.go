@@ -1,13 +1,13 @@
 package dbstarter
 
 import (
-	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"codeops.didachuxing.com/lordaeron/go-toolbox/config"
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
@@ -21,55 +21,320 @@ var (
 	DBUser     string
 	DBPassword string
 	DBCharset  string
+	DBDriver   string
 
 	DB *gorm.DB
 )
 
-func Init() error {
-	// 解析配置。
-	var (
-		ok1 bool
-		ok2 bool
-		ok3 bool
-		ok4 bool
-		ok5 bool
-		// ok bool
-	)
-	DBHost, ok1 = config.RawData["db_host"].(string)
-	DBPort, ok2 = config.RawData["db_port"].(int)
-	DBName, ok3 = config.RawData["db_name"].(string)
-	DBUser, ok4 = config.RawData["db_user"].(string)
-	DBPassword, ok5 = config.RawData["db_password"].(string)
-	if !(ok1 && ok2 && ok3 && ok4 && ok5) {
-		return errors.New("invalid db config")
-	}
-	if DBHost == "" || DBName == "" || DBUser == "" {
-		return errors.New("invalid db config. db_host, db_name, db_user cannot be empty")
-	}
-	if DBPort <= 0 {
-		DBPort = DEFAULT_DB_PORT
-	}
-	if charset, ok := config.RawData["db_charset"].(string); ok && charset != "" {
-		DBCharset = charset
-	} else {
-		DBCharset = DEFAULT_CHARSET
+// 单个数据库连接的配置，对应config中`databases`下的每一项
+type ConnConfig struct {
+	Driver   string `config:"driver"` // mysql(默认), postgres, sqlite
+	Host     string `config:"host"`
+	Port     int    `config:"port"`
+	Name     string `config:"name,required"` // sqlite为数据库文件路径，或':memory:'
+	User     string `config:"user"`
+	Password string `config:"password"`
+	Charset  string `config:"charset"`  // mysql默认utf8mb4，postgres默认UTF8
+	Timezone string `config:"timezone"` // mysql默认Local，postgres默认使用服务端设置
+	SSLMode  string `config:"sslmode"`  // postgres专用，默认disable
+
+	// 连接池参数，支持配置热加载
+	MaxIdleConns    int           `config:"max_idle_conns" default:"20"`      // 最大空闲连接数
+	MaxOpenConns    int           `config:"max_open_conns"`                   // 最大连接数限制，0表示不限制
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime" default:"1800"` // 连接最大复用时间，单位：秒。0表示不限制
+	ConnMaxIdleTime time.Duration `config:"conn_max_idle_time"`               // 连接最大空闲时间，单位：秒。0表示不限制
+
+	// 只读副本。配置后，Serializor等读操作将路由到副本，写操作及事务使用主库。
+	Replicas            []ReplicaConfig `config:"replicas"`
+	ReplicaPolicy       string          `config:"replica_policy" default:"round_robin"` // round_robin, least_conn
+	HealthCheckInterval time.Duration   `config:"health_check_interval" default:"10"`   // 副本健康检查间隔，单位：秒
+	MaxFailures         int             `config:"max_failures" default:"3"`             // 连续ping失败次数达到后剔除副本
+}
+
+// 对应config中db_*系列配置，即默认连接的配置
+type dbConfig struct {
+	Driver   string `config:"db_driver"`
+	Host     string `config:"db_host"`
+	Port     int    `config:"db_port"`
+	Name     string `config:"db_name,required"`
+	User     string `config:"db_user"`
+	Password string `config:"db_password"`
+	Charset  string `config:"db_charset"`
+	Timezone string `config:"db_timezone"`
+	SSLMode  string `config:"db_sslmode"`
+
+	MaxIdleConns    int           `config:"db_max_idle_conns" default:"20"`
+	MaxOpenConns    int           `config:"db_max_open_conns"`
+	ConnMaxLifetime time.Duration `config:"db_conn_max_lifetime" default:"1800"`
+	ConnMaxIdleTime time.Duration `config:"db_conn_max_idle_time"`
+
+	Replicas            []ReplicaConfig `config:"db_replicas"`
+	ReplicaPolicy       string          `config:"db_replica_policy" default:"round_robin"`
+	HealthCheckInterval time.Duration   `config:"db_health_check_interval" default:"10"`
+	MaxFailures         int             `config:"db_max_failures" default:"3"`
+}
+
+func (conf *dbConfig) connConfig() ConnConfig {
+	return ConnConfig{
+		Driver:       conf.Driver,
+		Timezone:     conf.Timezone,
+		SSLMode:      conf.SSLMode,
+		Host:         conf.Host,
+		Port:         conf.Port,
+		Name:         conf.Name,
+		User:         conf.User,
+		Password:     conf.Password,
+		Charset:      conf.Charset,
+		MaxIdleConns: conf.MaxIdleConns,
+		MaxOpenConns: conf.MaxOpenConns,
+
+		ConnMaxLifetime: conf.ConnMaxLifetime,
+		ConnMaxIdleTime: conf.ConnMaxIdleTime,
+
+		Replicas:            conf.Replicas,
+		ReplicaPolicy:       conf.ReplicaPolicy,
+		HealthCheckInterval: conf.HealthCheckInterval,
+		MaxFailures:         conf.MaxFailures,
+	}
+}
+
+// 对应config中的`databases`配置
+type databasesConfig struct {
+	Databases map[string]ConnConfig `config:"databases"`
+}
+
+// db_*系列配置，以及databases配置的校验规则，在config.Parse()时校验。
+func init() {
+	config.RegisterSchema("dbstarter", config.Schema{
+		KeyPrefix: "db_",
+		Rules: map[string]map[string]interface{}{
+			"db_driver":         {"type": "string", "choices": driverChoices()},
+			"db_host":           {"type": "string"},
+			"db_port":           {"type": "int", "min": 0, "max": 65535}, // 0为driver的默认端口
+			"db_name":           {"type": "string", "required": true, "not_empty": true},
+			"db_user":           {"type": "string"},
+			"db_password":       {"type": "string"},
+			"db_charset":        {"type": "string"},
+			"db_timezone":       {"type": "string"},
+			"db_sslmode":        {"type": "string"},
+			"db_max_idle_conns": {"type": "int", "min": 0},
+			"db_max_open_conns": {"type": "int", "min": 0},
+
+			"db_conn_max_lifetime":  {"type": "duration", "min": 0},
+			"db_conn_max_idle_time": {"type": "duration", "min": 0},
+			"db_stats_interval":     {"type": "duration", "min": 0},
+			"db_stats_history":      {"type": "int", "min": 0},
+			"db_slow_threshold":     {"type": "duration", "min": 0},
+			"db_log_sql_params":     {"type": "string", "choices": []interface{}{SQL_PARAMS_DEBUG, SQL_PARAMS_ALL, SQL_PARAMS_NONE}},
+
+			"db_replicas":              {"type": "list", "item_type": "dict"},
+			"db_replica_policy":        {"type": "string", "choices": []interface{}{POLICY_ROUND_ROBIN, POLICY_LEAST_CONN}},
+			"db_health_check_interval": {"type": "duration", "min": 0},
+			"db_max_failures":          {"type": "int", "min": 0},
+		},
+	})
+	config.RegisterSchema("dbstarter.databases", config.Schema{
+		Section: "databases.*",
+		Rules: map[string]map[string]interface{}{
+			"driver":         {"type": "string", "choices": driverChoices()},
+			"host":           {"type": "string"},
+			"port":           {"type": "int", "min": 0, "max": 65535},
+			"name":           {"type": "string", "required": true, "not_empty": true},
+			"user":           {"type": "string"},
+			"password":       {"type": "string"},
+			"charset":        {"type": "string"},
+			"timezone":       {"type": "string"},
+			"sslmode":        {"type": "string"},
+			"max_idle_conns": {"type": "int", "min": 0},
+			"max_open_conns": {"type": "int", "min": 0},
+
+			"conn_max_lifetime":  {"type": "duration", "min": 0},
+			"conn_max_idle_time": {"type": "duration", "min": 0},
+
+			"replicas":              {"type": "list", "item_type": "dict"},
+			"replica_policy":        {"type": "string", "choices": []interface{}{POLICY_ROUND_ROBIN, POLICY_LEAST_CONN}},
+			"health_check_interval": {"type": "duration", "min": 0},
+			"max_failures":          {"type": "int", "min": 0},
+		},
+	})
+}
+
+// 根据连接配置，打开一个数据库连接池。不包括副本。
+func Open(conf ConnConfig) (*gorm.DB, error) {
+	return open(conf, false)
+}
+
+// disablePing为true时，打开连接池时不检查连通性。
+func open(conf ConnConfig, disablePing bool) (*gorm.DB, error) {
+	if err := conf.check(); err != nil {
+		return nil, err
 	}
 
 	// 初始化数据库连接池
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local", DBUser, DBPassword, DBHost, DBPort, DBName, DBCharset)
-	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(conf.dialector(), &gorm.Config{DisableAutomaticPing: disablePing, Logger: sqlLogger{}})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := registerTraceCallbacks(db); err != nil {
+		closeDB(db, nil)
+		return nil, err
 	}
 
 	// 连接池参数设置
-	dbpool, err := DB.DB()
+	if err := setPool(db, conf); err != nil {
+		closeDB(db, nil)
+		return nil, err
+	}
+	return db, nil
+}
+
+func setPool(db *gorm.DB, conf ConnConfig) error {
+	dbpool, err := db.DB()
 	if err != nil {
 		return err
 	}
-	dbpool.SetMaxIdleConns(20)                  // 最大空闲连接数
-	dbpool.SetConnMaxLifetime(30 * time.Minute) //最大复用时间
-	// dbpool.SetMaxOpenConns(100)          // 最大连接数限制。
+	// sqlite内存数据库随连接关闭而消失，且每个连接都是一个独立的数据库，因此只使用一个常驻的连接
+	if conf.Driver == DRIVER_SQLITE && conf.Name == SQLITE_MEMORY {
+		conf.MaxOpenConns, conf.MaxIdleConns = 1, 1
+		conf.ConnMaxLifetime, conf.ConnMaxIdleTime = 0, 0
+	}
+	dbpool.SetMaxIdleConns(conf.MaxIdleConns)
+	dbpool.SetMaxOpenConns(conf.MaxOpenConns)
+	dbpool.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	dbpool.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+	return nil
+}
+
+// 初始化数据库连接。
+//
+// 默认连接由db_*系列配置生成，赋值给DB，同时以DEFAULT_CONN_NAME注册。
+// `databases`配置中的每一项，以其key为名称注册，可通过Get(name)获取。
+// 若`databases`中定义了DEFAULT_CONN_NAME，则其作为默认连接，此时可不提供db_*系列配置。
+func Init() error {
+	// 解析配置。
+	dbsConf := databasesConfig{}
+	if err := config.Bind("", &dbsConf); err != nil {
+		return err
+	}
+	_, hasDefault := dbsConf.Databases[DEFAULT_CONN_NAME]
+	_, hasDBName := config.Get("db_name")
+
+	// 默认连接
+	if hasDBName || !hasDefault {
+		conf := dbConfig{}
+		if err := config.Bind("", &conf); err != nil {
+			return err
+		}
+		connConf := conf.connConfig()
+		db, err := openCluster(DEFAULT_CONN_NAME, connConf)
+		if err != nil {
+			return err
+		}
+		if err := setDefault(db, connConf); err != nil {
+			return err
+		}
+	}
+
+	// 命名连接
+	for name, conf := range dbsConf.Databases {
+		if name == DEFAULT_CONN_NAME && hasDBName {
+			return fmt.Errorf("database '%s' is defined by both db_* config and 'databases' config", name)
+		}
+		db, err := openCluster(name, conf)
+		if err != nil {
+			return fmt.Errorf("failed to open database '%s'. %s", name, err.Error())
+		}
+		if name == DEFAULT_CONN_NAME {
+			if err := setDefault(db, conf); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 连接池状态采集
+	statsConf := statsConfig{}
+	if err := config.Bind("", &statsConf); err != nil {
+		return err
+	}
+	StartStatsCollector(statsConf.Interval, statsConf.History)
+
+	// SQL日志
+	if err := setLogConfig(); err != nil {
+		return err
+	}
+
+	subscribeOnce.Do(subscribe)
 	return nil
 }
+
+// 热加载的订阅只注册一次，重复调用Init()时不会重复调整连接池
+var subscribeOnce sync.Once
+
+func subscribe() {
+	// 配置热加载时，调整连接池参数
+	for _, key := range []string{"db_max_idle_conns", "db_max_open_conns", "db_conn_max_lifetime", "db_conn_max_idle_time", "databases"} {
+		config.Subscribe(key, resizePools)
+	}
+	for _, key := range []string{"db_slow_threshold", "db_log_sql_params"} {
+		config.Subscribe(key, func(key string, oldVal, newVal interface{}) {
+			if err := setLogConfig(); err != nil {
+				slog.Error(fmt.Sprintf("dbstarter: failed to change sql log config. %s", err.Error()))
+			}
+		})
+	}
+}
+
+func setLogConfig() error {
+	logConf := logConfig{}
+	if err := config.Bind("", &logConf); err != nil {
+		return err
+	}
+	SetSlowThreshold(logConf.SlowThreshold)
+	return SetSQLParams(logConf.SQLParams)
+}
+
+// 设置默认连接相关的全局变量
+func setDefault(db *gorm.DB, conf ConnConfig) error {
+	// 填充默认端口等，与打开连接时一致
+	if err := conf.check(); err != nil {
+		return err
+	}
+	DB = db
+	DBHost, DBPort, DBName, DBUser, DBPassword, DBCharset = conf.Host, conf.Port, conf.Name, conf.User, conf.Password, conf.Charset
+	DBDriver = conf.Driver
+	return nil
+}
+
+func resizePools(key string, oldVal, newVal interface{}) {
+	confs := map[string]ConnConfig{}
+	if _, ok := config.Get("db_name"); ok {
+		conf := dbConfig{}
+		if err := config.Bind("", &conf); err != nil {
+			slog.Error(fmt.Sprintf("dbstarter: failed to resize db pool. %s", err.Error()))
+			return
+		}
+		confs[DEFAULT_CONN_NAME] = conf.connConfig()
+	}
+	dbsConf := databasesConfig{}
+	if err := config.Bind("", &dbsConf); err != nil {
+		slog.Error(fmt.Sprintf("dbstarter: failed to resize db pool. %s", err.Error()))
+		return
+	}
+	for name, conf := range dbsConf.Databases {
+		confs[name] = conf
+	}
+
+	for name, conf := range confs {
+		c, err := getCluster(name)
+		if err != nil {
+			continue // 新增的连接不会在热加载时打开
+		}
+		for _, db := range c.all() {
+			if err := setPool(db, conf); err != nil {
+				slog.Error(fmt.Sprintf("dbstarter: failed to resize db pool '%s'. %s", name, err.Error()))
+			}
+		}
+		slog.Info(fmt.Sprintf("dbstarter: db pool '%s' resized, max_idle_conns: %d, max_open_conns: %d, conn_max_lifetime: %s, conn_max_idle_time: %s.",
+			name, conf.MaxIdleConns, conf.MaxOpenConns, conf.ConnMaxLifetime, conf.ConnMaxIdleTime))
+	}
+}
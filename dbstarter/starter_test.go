@@ -0,0 +1,98 @@
+package dbstarter
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/config"
+)
+
+func TestReloadPoolConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig := func(maxOpenConns int, slowThreshold string) {
+		t.Helper()
+		content := "db_driver: sqlite\n" +
+			"db_name: " + filepath.Join(dir, "test.db") + "\n" +
+			"db_max_open_conns: " + strconv.Itoa(maxOpenConns) + "\n" +
+			"db_slow_threshold: " + slowThreshold + "\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(2, "100ms")
+	oldFiles, oldDB := config.ConfigFiles, DB
+	config.ConfigFiles = []string{path}
+	t.Cleanup(func() {
+		config.ConfigFiles, DB = oldFiles, oldDB
+		config.Parse()
+		SetSlowThreshold(DEFAULT_SLOW_THRESHOLD)
+		connLock.Lock()
+		c := conns[DEFAULT_CONN_NAME]
+		delete(conns, DEFAULT_CONN_NAME)
+		connLock.Unlock()
+		if c != nil {
+			c.close(nil)
+		}
+	})
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	maxOpenConns := func() int {
+		db, err := Get(DEFAULT_CONN_NAME)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlDB, _ := db.DB()
+		return sqlDB.Stats().MaxOpenConnections
+	}
+	if maxOpenConns() != 2 || atomic.LoadInt64(&slowThreshold) != int64(100*time.Millisecond) {
+		t.Fatalf("unexpected initial config, max open conns %d", maxOpenConns())
+	}
+
+	writeConfig(5, "300ms")
+	if err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if maxOpenConns() != 5 {
+		t.Errorf("expect max open conns 5 after reload, got %d", maxOpenConns())
+	}
+	if threshold := time.Duration(atomic.LoadInt64(&slowThreshold)); threshold != 300*time.Millisecond {
+		t.Errorf("expect slow threshold 300ms after reload, got %s", threshold)
+	}
+}
+
+func TestPortZero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "db_host: h\ndb_user: u\ndb_name: n\ndb_port: 0\n" +
+		"databases:\n  other: {host: h, user: u, name: n, port: 0}\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldFiles := config.ConfigFiles
+	config.ConfigFiles = []string{path}
+	t.Cleanup(func() {
+		config.ConfigFiles = oldFiles
+		config.Parse()
+	})
+	// 端口为0时使用driver的默认端口
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	conf := dbConfig{}
+	if err := config.Bind("", &conf); err != nil {
+		t.Fatal(err)
+	}
+	connConf := conf.connConfig()
+	if err := connConf.check(); err != nil || connConf.Port != DEFAULT_DB_PORT {
+		t.Errorf("expect default port %d, got %d, %v", DEFAULT_DB_PORT, connConf.Port, err)
+	}
+}
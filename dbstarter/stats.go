@@ -0,0 +1,131 @@
+package dbstarter
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+)
+
+const DEFAULT_STATS_INTERVAL = 60 * time.Second
+const DEFAULT_STATS_HISTORY = 60
+
+// 连接池状态采集的配置
+type statsConfig struct {
+	Interval time.Duration `config:"db_stats_interval" default:"60"` // 采集间隔，单位：秒。0表示不采集
+	History  int           `config:"db_stats_history" default:"60"`  // 每个连接池保留的采样数
+}
+
+// 连接池的一次采样。
+// WaitCountDelta、WaitDurationDelta为与上一次采样相比的增量，持续增长说明连接池已耗尽。
+type PoolStats struct {
+	Time time.Time
+	Name string
+	sql.DBStats
+
+	WaitCountDelta    int64
+	WaitDurationDelta time.Duration
+}
+
+// 连接池是否耗尽：采样期间有等待，且使用中的连接数已达上限。
+func (s PoolStats) Exhausted() bool {
+	return s.WaitCountDelta > 0 && s.MaxOpenConnections > 0 && s.InUse >= s.MaxOpenConnections
+}
+
+var (
+	statsHistory = map[string][]PoolStats{}
+	statsLock    sync.RWMutex
+	statsStop    chan struct{}
+)
+
+// 当前所有连接池的状态。主库以连接名称为key，副本以`name@host:port`为key。
+func Stats() map[string]sql.DBStats {
+	res := map[string]sql.DBStats{}
+	for _, name := range Names() {
+		c, err := getCluster(name)
+		if err != nil {
+			continue
+		}
+		for key, db := range c.pools(name) {
+			sqlDB, err := db.DB()
+			if err != nil {
+				continue
+			}
+			res[key] = sqlDB.Stats()
+		}
+	}
+	return res
+}
+
+// 连接池的历史采样，按时间升序。name同Stats()的key。
+func StatsHistory(name string) []PoolStats {
+	statsLock.RLock()
+	defer statsLock.RUnlock()
+	history := statsHistory[name]
+	res := make([]PoolStats, len(history))
+	copy(res, history)
+	return res
+}
+
+// 启动连接池状态采集，每interval采样一次，每个连接池保留最近history个采样。
+// 重复调用将替换之前的采集协程；interval<=0时仅停止采集。
+func StartStatsCollector(interval time.Duration, history int) {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	if statsStop != nil {
+		close(statsStop)
+		statsStop = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	if history <= 0 {
+		history = DEFAULT_STATS_HISTORY
+	}
+	stop := make(chan struct{})
+	statsStop = stop
+	go collectStats(interval, history, stop)
+}
+
+func collectStats(interval time.Duration, history int, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		current := Stats()
+		keys := make([]string, 0, len(current))
+		for key := range current {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		statsLock.Lock()
+		for _, key := range keys {
+			sample := PoolStats{Time: now, Name: key, DBStats: current[key]}
+			if prev := statsHistory[key]; len(prev) > 0 {
+				last := prev[len(prev)-1]
+				sample.WaitCountDelta = sample.WaitCount - last.WaitCount
+				sample.WaitDurationDelta = sample.WaitDuration - last.WaitDuration
+			}
+			list := append(statsHistory[key], sample)
+			if len(list) > history {
+				list = list[len(list)-history:]
+			}
+			statsHistory[key] = list
+
+			if sample.Exhausted() {
+				slog.Warning(fmt.Sprintf("dbstarter: db pool '%s' exhausted, in_use: %d, max_open_conns: %d, wait_count: +%d, wait_duration: +%s.",
+					key, sample.InUse, sample.MaxOpenConnections, sample.WaitCountDelta, sample.WaitDurationDelta))
+			}
+		}
+		statsLock.Unlock()
+	}
+}
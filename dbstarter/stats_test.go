@@ -0,0 +1,78 @@
+package dbstarter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatsHistory(t *testing.T) {
+	name, db := newTestDB(t)
+	t.Cleanup(func() {
+		StartStatsCollector(0, 0)
+		statsLock.Lock()
+		delete(statsHistory, name)
+		statsLock.Unlock()
+	})
+	if _, ok := Stats()[name]; !ok {
+		t.Fatalf("expect stats of '%s'", name)
+	}
+
+	// 内存数据库只有一个连接，占用后其他查询需等待
+	sqlDB, _ := db.DB()
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	sqlDB.PingContext(ctx)
+	cancel()
+
+	StartStatsCollector(5*time.Millisecond, 3)
+	waitSamples := func(n int) []PoolStats {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if history := StatsHistory(name); len(history) >= n {
+				return history
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expect %d samples of '%s'", n, name)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	first := waitSamples(3)
+
+	// 超过history后只保留最近的采样
+	time.Sleep(50 * time.Millisecond)
+	history := waitSamples(3)
+	if len(history) != 3 {
+		t.Fatalf("expect 3 samples, got %d", len(history))
+	}
+	if !history[0].Time.After(first[2].Time) {
+		t.Error("expect old samples to be dropped")
+	}
+	for i := 1; i < len(history); i++ {
+		if !history[i].Time.After(history[i-1].Time) {
+			t.Errorf("expect samples in ascending order, got %v", history)
+		}
+	}
+	if first[0].InUse != 1 || first[0].WaitCount == 0 {
+		t.Errorf("unexpected sample %+v", first[0])
+	}
+
+	// 返回的是副本
+	history[0].InUse = 100
+	if StatsHistory(name)[0].InUse == 100 {
+		t.Error("StatsHistory should return a copy")
+	}
+	conn.Close()
+
+	if history := StatsHistory("no_such_db"); len(history) != 0 {
+		t.Errorf("expect no samples for unknown name, got %v", history)
+	}
+	if _, ok := Stats()["no_such_db"]; ok {
+		t.Error("unexpected stats for unknown name")
+	}
+}
@@ -0,0 +1,136 @@
+package dbstarter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"time"
+
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+const DEFAULT_TX_MAX_RETRIES = 3
+const DEFAULT_TX_BACKOFF = 50 * time.Millisecond
+
+// 可重试的mysql错误码
+const (
+	MYSQL_ER_LOCK_WAIT_TIMEOUT = 1205
+	MYSQL_ER_LOCK_DEADLOCK     = 1213
+)
+
+// WithTx的选项，nil表示全部使用默认值
+type TxOptions struct {
+	Conn       string             // 数据库连接名称，空表示默认连接
+	Isolation  sql.IsolationLevel // 隔离级别，默认使用数据库的设置
+	ReadOnly   bool
+	MaxRetries int           // 死锁、锁等待超时时的最大重试次数，默认DEFAULT_TX_MAX_RETRIES，小于0表示不重试
+	Backoff    time.Duration // 首次重试前的等待时间，之后每次翻倍，默认DEFAULT_TX_BACKOFF
+}
+
+// 事务中发生panic时返回的错误
+type TxPanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *TxPanicError) Error() string {
+	return fmt.Sprintf("panic in transaction: %v", e.Value)
+}
+
+type txContextKey struct{}
+
+// 获取ctx中正在进行的事务，没有时返回nil
+func TxFromContext(ctx context.Context) *gorm.DB {
+	tx, _ := ctx.Value(txContextKey{}).(*gorm.DB)
+	return tx
+}
+
+// 获取名称对应的主库连接
+func getPrimary(name string) (*gorm.DB, error) {
+	if name == "" {
+		name = DEFAULT_CONN_NAME
+	}
+	db, err := Get(name)
+	if err != nil && name == DEFAULT_CONN_NAME && DB != nil {
+		return DB, nil // 兼容直接设置全局变量DB的用法
+	}
+	return db, err
+}
+
+// 是否为可重试的错误：mysql死锁、锁等待超时
+func isRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == MYSQL_ER_LOCK_DEADLOCK || mysqlErr.Number == MYSQL_ER_LOCK_WAIT_TIMEOUT
+	}
+	return false
+}
+
+// 在事务中执行fn。fn返回nil时提交，返回错误或panic时回滚，panic以TxPanicError返回。
+//
+// fn中的tx携带了事务信息，以tx.Statement.Context再次调用WithTx时，使用savepoint实现嵌套事务，此时opts无效，也不会重试。
+// 最外层事务遇到mysql死锁(1213)、锁等待超时(1205)时，按指数退避重试整个事务，因此fn应当可以重复执行。
+func WithTx(ctx context.Context, opts *TxOptions, fn func(tx *gorm.DB) error) error {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+
+	// 嵌套事务
+	if parent := TxFromContext(ctx); parent != nil {
+		return parent.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return runTxFunc(ctx, tx, fn)
+		})
+	}
+
+	db, err := getPrimary(opts.Conn)
+	if err != nil {
+		return err
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DEFAULT_TX_MAX_RETRIES
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = DEFAULT_TX_BACKOFF
+	}
+	txOpts := &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+
+	for attempt := 0; ; attempt++ {
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return runTxFunc(ctx, tx, fn)
+		}, txOpts)
+		if err == nil || attempt >= maxRetries || !isRetryableTxError(err) {
+			return err
+		}
+
+		// 指数退避，加上随机抖动以避免冲突的事务同时重试
+		wait := backoff << uint(attempt)
+		wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+		slog.Warning(fmt.Sprintf("dbstarter: transaction failed, retry %d/%d after %s. %s", attempt+1, maxRetries, wait, err.Error()))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// 执行fn，将panic转换为错误，以便回滚
+func runTxFunc(ctx context.Context, tx *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr := &TxPanicError{Value: r, Stack: debug.Stack()}
+			slog.Error(fmt.Sprintf("dbstarter: %s\n%s", panicErr.Error(), panicErr.Stack))
+			err = panicErr
+		}
+	}()
+	tx = tx.WithContext(context.WithValue(ctx, txContextKey{}, tx))
+	return fn(tx)
+}
@@ -0,0 +1,122 @@
+package dbstarter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestWithTx(t *testing.T) {
+	conn, db := newTestDB(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+	opts := &TxOptions{Conn: conn}
+	ctx := context.Background()
+	insert := func(tx *gorm.DB, name string) error {
+		return tx.Exec("INSERT INTO items (name) VALUES (?)", name).Error
+	}
+	errFailed := errors.New("failed")
+
+	// 提交
+	if err := WithTx(ctx, opts, func(tx *gorm.DB) error { return insert(tx, "commit") }); err != nil {
+		t.Fatal(err)
+	}
+	// 返回错误时回滚
+	err := WithTx(ctx, opts, func(tx *gorm.DB) error {
+		if err := insert(tx, "rollback"); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if err != errFailed {
+		t.Errorf("expect errFailed, got %v", err)
+	}
+	// panic时回滚，并返回TxPanicError
+	err = WithTx(ctx, opts, func(tx *gorm.DB) error {
+		insert(tx, "panic")
+		panic("boom")
+	})
+	var panicErr *TxPanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Errorf("expect a TxPanicError, got %v", err)
+	}
+
+	// 嵌套事务：内层失败只回滚到savepoint，外层继续并提交
+	err = WithTx(ctx, opts, func(tx *gorm.DB) error {
+		if TxFromContext(tx.Statement.Context) == nil {
+			t.Error("expect a transaction in context")
+		}
+		if err := insert(tx, "outer"); err != nil {
+			return err
+		}
+		err := WithTx(tx.Statement.Context, nil, func(tx *gorm.DB) error {
+			insert(tx, "inner rollback")
+			return errFailed
+		})
+		if err != errFailed {
+			t.Errorf("expect errFailed from nested transaction, got %v", err)
+		}
+		err = WithTx(tx.Statement.Context, nil, func(tx *gorm.DB) error {
+			insert(tx, "inner panic")
+			panic("nested boom")
+		})
+		if !errors.As(err, &panicErr) {
+			t.Errorf("expect a TxPanicError from nested transaction, got %v", err)
+		}
+		return WithTx(tx.Statement.Context, nil, func(tx *gorm.DB) error { return insert(tx, "inner commit") })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	db.Table("items").Order("id").Pluck("name", &names)
+	if want := []string{"commit", "outer", "inner commit"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expect %v, got %v", want, names)
+	}
+}
+
+func TestWithTxRetry(t *testing.T) {
+	conn, _ := newTestDB(t)
+	deadlock := &mysql.MySQLError{Number: MYSQL_ER_LOCK_DEADLOCK, Message: "Deadlock found"}
+	ctx := context.Background()
+
+	cases := []struct {
+		opts     *TxOptions
+		failures int
+		attempts int
+		err      error
+	}{
+		{&TxOptions{Conn: conn, Backoff: time.Millisecond}, 2, 3, nil},
+		{&TxOptions{Conn: conn, Backoff: time.Millisecond}, 10, DEFAULT_TX_MAX_RETRIES + 1, deadlock},
+		{&TxOptions{Conn: conn, Backoff: time.Millisecond, MaxRetries: 1}, 10, 2, deadlock},
+		{&TxOptions{Conn: conn, MaxRetries: -1}, 10, 1, deadlock},
+	}
+	for i, c := range cases {
+		attempts := 0
+		err := WithTx(ctx, c.opts, func(tx *gorm.DB) error {
+			attempts++
+			if attempts <= c.failures {
+				return deadlock
+			}
+			return nil
+		})
+		if err != c.err || attempts != c.attempts {
+			t.Errorf("case %d: expect %d attempts and %v, got %d and %v", i, c.attempts, c.err, attempts, err)
+		}
+	}
+
+	// 不可重试的错误
+	attempts := 0
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	WithTx(ctx, &TxOptions{Conn: conn}, func(tx *gorm.DB) error {
+		attempts++
+		return duplicate
+	})
+	if attempts != 1 {
+		t.Errorf("expect no retry for duplicate entry, got %d attempts", attempts)
+	}
+}
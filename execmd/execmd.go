@@ -1,27 +1,82 @@
 package execmd
 
 import (
+	"context"
 	"errors"
 	"io"
+	"os"
 	"os/exec"
+	"sync"
+	"time"
 )
 
+// 超时或取消后，发送SIGTERM到发送SIGKILL之间的默认等待时间
+const DEFAULT_KILL_DELAY = 5 * time.Second
+
+// 超时或取消终止命令后，等待输出管道关闭的默认最长时间
+const DEFAULT_WAIT_DELAY = time.Second
 
 type Result struct {
-	ExitCode 	int 	`json:"exit_code"`
-	Stdout		string	`json:"stdout"`
-	Stderr 		string	`json:"stderr"`
+	ExitCode  int    `json:"exit_code"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Combined  string `json:"combined,omitempty"` // RunOptions.Combined为true时，stdout、stderr按行交错合并的日志
+	Truncated bool   `json:"truncated"`          // 输出是否超出MaxOutput而被截断
+	TimedOut  bool   `json:"timed_out"`          // 是否因超时被终止
+}
+
+// RunContext的执行选项
+type RunOptions struct {
+	Timeout time.Duration // 执行超时时间，0表示不限制(仍受ctx约束)
+	// 超时或ctx取消后，先向进程组发送SIGTERM，等待KillDelay后仍未退出则发送SIGKILL。0表示DEFAULT_KILL_DELAY，<0表示直接SIGKILL。
+	// 命令提前退出时，仍会向进程组发送SIGKILL，终止忽略SIGTERM的后代进程
+	KillDelay time.Duration
+	// 终止命令后，等待输出管道关闭的最长时间，到时关闭管道并返回，之后的输出被丢弃。0表示DEFAULT_WAIT_DELAY。
+	// 用于脱离了进程组的后代进程(如setsid、nohup启动的后台进程)仍持有管道的情况
+	WaitDelay time.Duration
+
+	// stdout、stderr、Combined各自最多捕获的字节数，超出时保留开头和结尾各一半，中间注明截断的字节数。
+	// <=0表示不限制(默认，与Run一致)，输出可能很大的命令应设置该值
+	MaxOutput int
+	// 为true时，生成Result.Combined：每行格式为`<COMBINED_TIME_FORMAT> [stdout|stderr] <line>`，按输出的先后顺序排列
+	Combined bool
 }
 
 func Run(strCmd string, args ...string) (*Result, error) {
 	/*
-	如果不提供args，则会被认为是一个onelineCmd，将用'bash -c'来执行。
+		如果不提供args，则会被认为是一个onelineCmd，将用'bash -c'来执行。
 
-	提供了args，则strCmd被认为是一个program，将按照exec原生的方式执行。
+		提供了args，则strCmd被认为是一个program，将按照exec原生的方式执行。
 	*/
+	return RunContext(context.Background(), strCmd, args...)
+}
+
+// 同Run，ctx取消或超时时终止命令，使用默认的RunOptions。
+func RunContext(ctx context.Context, strCmd string, args ...string) (*Result, error) {
+	return RunOptions{}.RunContext(ctx, strCmd, args...)
+}
 
+// 同Run，按opts执行。
+//
+// ctx可取消或设置了Timeout时，命令在独立的进程组中执行，超时或ctx取消时终止整个进程组，包括'bash -c'启动的子进程。
+// 此时返回的Result包含已输出的内容，ExitCode为-1(进程处理SIGTERM后自行退出时为其退出码)，
+// 同时返回ctx的错误：超时为context.DeadlineExceeded，且Result.TimedOut为true；取消为context.Canceled。
+// 终止后最多再等待WaitDelay读取输出，即使有后代进程仍持有输出管道也会返回。
+//
+// 否则(如Run)命令与调用方在同一进程组中，终端的Ctrl-C等信号会同时发送给命令，与直接exec.Command的行为一致。
+func (opts RunOptions) RunContext(ctx context.Context, strCmd string, args ...string) (*Result, error) {
 	res := Result{ExitCode: -1}
 
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		res.TimedOut = errors.Is(err, context.DeadlineExceeded)
+		return &res, err
+	}
+
 	// Init
 	var cmd *exec.Cmd
 	if len(args) == 0 {
@@ -29,40 +84,175 @@ func Run(strCmd string, args ...string) (*Result, error) {
 	} else {
 		cmd = exec.Command(strCmd, args...)
 	}
-	stdout, _ := cmd.StdoutPipe()
-	stderr, _ := cmd.StderrPipe()
+	if ctx.Done() != nil {
+		setProcessGroup(cmd)
+	}
 
-	// Start to execute. Do record.
-	if err := cmd.Start(); err != nil {
+	var combined *combinedLog
+	if opts.Combined {
+		combined = &combinedLog{buf: newCapBuffer(opts.MaxOutput)}
+	}
+	stdout := &streamWriter{name: "stdout", buf: newCapBuffer(opts.MaxOutput), combined: combined}
+	stderr := &streamWriter{name: "stderr", buf: newCapBuffer(opts.MaxOutput), combined: combined}
+
+	// 使用自己创建的管道，而非由os/exec读取，以便终止后可以不等待管道关闭
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
 		return nil, err
 	}
-	stdoutBytes, err := io.ReadAll(stdout)
+	stderrR, stderrW, err := os.Pipe()
 	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
 		return nil, err
 	}
-	stderrBytes, err := io.ReadAll(stderr)
+	defer stdoutR.Close()
+	defer stderrR.Close()
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+
+	// Start to execute. Do record.
+	err = cmd.Start()
+	// 子进程已继承写端，父进程关闭自己的写端，否则读取不会结束
+	stdoutW.Close()
+	stderrW.Close()
 	if err != nil {
 		return nil, err
 	}
-	
-	// Wait until cmd executing complete.
-	if err := cmd.Wait(); err != nil {
-		exitErr, ok := err.(*exec.ExitError)
+
+	// stdout、stderr在各自的协程中同时读取，所有写端关闭后结束
+	copied := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, stream := range []struct {
+		w *streamWriter
+		r *os.File
+	}{{stdout, stdoutR}, {stderr, stderrR}} {
+		wg.Add(1)
+		go func(w *streamWriter, r *os.File) {
+			defer wg.Done()
+			io.Copy(w, r)
+		}(stream.w, stream.r)
+	}
+	go func() {
+		wg.Wait()
+		close(copied)
+	}()
+
+	// 等待命令退出。支持时(linux)只等待而不回收，回收前进程组ID不会被复用，终止时可安全地向进程组发送信号
+	var waitErr error
+	reaped := false
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		if !waitExited(cmd) {
+			waitErr = cmd.Wait()
+			reaped = true
+		}
+	}()
+	finished := make(chan struct{})
+	go func() {
+		<-exited
+		<-copied
+		close(finished)
+	}()
+
+	// Wait until cmd executing complete. 输出的管道关闭后才返回；超时或取消时终止进程组，之后最多等待WaitDelay。
+	terminated := false
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		terminated = true
+		opts.terminate(cmd, exited, copied)
+	}
+	<-exited
+	if !reaped {
+		waitErr = cmd.Wait()
+	}
+	if terminated {
+		opts.waitOutput(copied, stdoutR, stderrR)
+	}
+
+	// Store results.
+	stdout.flush()
+	stderr.flush()
+	res.Stdout = stdout.buf.String()
+	res.Stderr = stderr.buf.String()
+	res.Truncated = stdout.buf.Truncated() || stderr.buf.Truncated()
+	if combined != nil {
+		res.Combined = combined.buf.String()
+		res.Truncated = res.Truncated || combined.buf.Truncated()
+	}
+
+	if terminated {
+		// 已终止，不论进程如何退出，均以ctx的错误返回
+		if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+			res.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		res.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		return &res, ctx.Err()
+	}
+
+	if waitErr != nil {
+		exitErr, ok := waitErr.(*exec.ExitError)
 		if ok {
 			res.ExitCode = exitErr.ProcessState.ExitCode()
 			if res.ExitCode == -1 {
 				return nil, errors.New("ERROR: Cmd process was not started successfully or has been killed!")
 			}
 		} else {
-			return nil, err
+			return nil, waitErr
 		}
 	} else {
 		res.ExitCode = 0
 	}
 
-	// Store results.
-	res.Stdout = string(stdoutBytes)
-	res.Stderr = string(stderrBytes)
-
 	return &res, nil
 }
+
+func (opts RunOptions) waitDelay() time.Duration {
+	if opts.WaitDelay <= 0 {
+		return DEFAULT_WAIT_DELAY
+	}
+	return opts.WaitDelay
+}
+
+// 等待输出读取结束，超过WaitDelay时关闭读端，使读取的协程结束
+func (opts RunOptions) waitOutput(copied <-chan struct{}, readers ...*os.File) {
+	timer := time.NewTimer(opts.waitDelay())
+	defer timer.Stop()
+	select {
+	case <-copied:
+	case <-timer.C:
+		for _, r := range readers {
+			r.Close()
+		}
+		<-copied
+	}
+}
+
+// 向进程组发送SIGTERM，等待命令退出，最多KillDelay；命令退出后仍有进程持有输出管道时，最多再等待WaitDelay(不超过KillDelay)。
+// 之后总是向进程组发送SIGKILL，终止忽略SIGTERM的后代进程。KillDelay<0时直接发送SIGKILL。
+// 调用时命令尚未被回收(不支持只等待不回收的系统除外)，进程组ID不会被复用。
+func (opts RunOptions) terminate(cmd *exec.Cmd, exited, copied <-chan struct{}) {
+	delay := opts.KillDelay
+	if delay == 0 {
+		delay = DEFAULT_KILL_DELAY
+	}
+	if delay > 0 {
+		terminateGroup(cmd)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-exited:
+			waitTimer := time.NewTimer(opts.waitDelay())
+			defer waitTimer.Stop()
+			select {
+			case <-copied:
+			case <-waitTimer.C:
+			case <-timer.C:
+			}
+		case <-timer.C:
+		}
+	}
+	killGroup(cmd)
+}
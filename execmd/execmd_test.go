@@ -0,0 +1,138 @@
+//go:build !windows
+// +build !windows
+
+package execmd
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	res, err := Run("echo out; echo err >&2; exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 3 || res.Stdout != "out\n" || res.Stderr != "err\n" || res.TimedOut || res.Truncated {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	if res, err = Run("printf", "%s-%s", "a b", "c"); err != nil || res.ExitCode != 0 || res.Stdout != "a b-c" {
+		t.Errorf("unexpected result %+v, %v", res, err)
+	}
+	if _, err := Run("/no/such/command", "x"); err == nil {
+		t.Error("expect an error for missing command")
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	cases := []struct {
+		cmd  string
+		opts RunOptions
+	}{
+		// 子进程与bash一起被终止
+		{"echo start; sleep 30 & wait", RunOptions{Timeout: 200 * time.Millisecond}},
+		// 忽略SIGTERM时，KillDelay后被SIGKILL
+		{"trap '' TERM; echo start; sleep 30", RunOptions{Timeout: 200 * time.Millisecond, KillDelay: 200 * time.Millisecond}},
+		// 脱离进程组的后代进程仍持有输出管道，WaitDelay后返回
+		{"echo start; setsid sleep 30 & wait", RunOptions{Timeout: 200 * time.Millisecond, WaitDelay: 200 * time.Millisecond}},
+	}
+	for _, c := range cases {
+		start := time.Now()
+		res, err := c.opts.RunContext(context.Background(), c.cmd)
+		elapsed := time.Since(start)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%q: expect DeadlineExceeded, got %v", c.cmd, err)
+			continue
+		}
+		if !res.TimedOut || res.Stdout != "start\n" {
+			t.Errorf("%q: unexpected result %+v", c.cmd, res)
+		}
+		if elapsed > 2*time.Second {
+			t.Errorf("%q: expect to return soon after timeout, took %s", c.cmd, elapsed)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunContext(ctx, "echo never"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expect Canceled, got %v", err)
+	}
+}
+
+// 进程已退出(包括未被回收的僵尸进程)
+func processGone(pid string) bool {
+	out, err := exec.Command("ps", "-o", "stat=", "-p", pid).Output()
+	return err != nil || strings.HasPrefix(strings.TrimSpace(string(out)), "Z")
+}
+
+func TestRunKillsGroup(t *testing.T) {
+	cmds := []string{
+		// bash收到SIGTERM后退出，忽略SIGTERM的子进程仍持有输出管道
+		`(trap "" TERM; exec sleep 7777) & echo $!; wait`,
+		// 子进程不持有输出管道
+		`(trap "" TERM; exec sleep 7777 >/dev/null 2>&1) & echo $!; wait`,
+	}
+	for _, cmd := range cmds {
+		for _, killDelay := range []time.Duration{300 * time.Millisecond, -1} {
+			opts := RunOptions{Timeout: 200 * time.Millisecond, KillDelay: killDelay}
+			res, err := opts.RunContext(context.Background(), cmd)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%q: expect DeadlineExceeded, got %v", cmd, err)
+				continue
+			}
+			pid := strings.TrimSpace(res.Stdout)
+			deadline := time.Now().Add(time.Second)
+			for !processGone(pid) {
+				if time.Now().After(deadline) {
+					exec.Command("kill", "-9", pid).Run()
+					t.Errorf("%q, kill delay %s: process %s should be killed", cmd, killDelay, pid)
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	// 默认不限制输出
+	res, err := Run("head -c 5000000 /dev/zero | tr '\\0' a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Stdout) != 5000000 || res.Truncated {
+		t.Errorf("expect full output, got %d bytes, truncated %v", len(res.Stdout), res.Truncated)
+	}
+
+	opts := RunOptions{MaxOutput: 10, Combined: true}
+	res, err = opts.RunContext(context.Background(), "echo 0123456789abcdef; echo err >&2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || !strings.HasPrefix(res.Stdout, "01234\n") || !strings.HasSuffix(res.Stdout, "cdef\n") {
+		t.Errorf("unexpected truncated output %q", res.Stdout)
+	}
+	if !strings.Contains(res.Combined, "bytes truncated") || !strings.HasSuffix(res.Combined, "err\n") {
+		t.Errorf("unexpected combined output %q", res.Combined)
+	}
+
+	opts = RunOptions{Combined: true}
+	if res, err = opts.RunContext(context.Background(), "echo a; sleep 0.1; echo b >&2; sleep 0.1; printf c"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(res.Combined, "\n"), "\n")
+	want := []string{"[stdout] a", "[stderr] b", "[stdout] c"}
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected combined output %q", res.Combined)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) {
+			t.Errorf("line %d: expect %q, got %q", i, want[i], line)
+		}
+	}
+}
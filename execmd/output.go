@@ -0,0 +1,121 @@
+package execmd
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 合并日志中每行的时间格式
+const COMBINED_TIME_FORMAT = "2006-01-02 15:04:05.000"
+
+// 合并日志中单行的最大长度，超出时拆分为多行
+const COMBINED_MAX_LINE = 64 * 1024
+
+// 只保留开头及结尾各一半内容的缓冲区，总长度不超过max，max<=0表示不限制。
+// 超出时，String()在开头与结尾之间插入被截断的字节数。
+type capBuffer struct {
+	max   int
+	head  []byte
+	tail  []byte
+	total int
+}
+
+func newCapBuffer(max int) *capBuffer {
+	return &capBuffer{max: max}
+}
+
+func (b *capBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if b.max <= 0 {
+		b.head = append(b.head, p...)
+		return len(p), nil
+	}
+
+	data := p
+	headMax := b.max / 2
+	if n := headMax - len(b.head); n > 0 {
+		if n > len(data) {
+			n = len(data)
+		}
+		b.head = append(b.head, data[:n]...)
+		data = data[n:]
+	}
+
+	// 结尾部分超出两倍时才丢弃，避免每次写入都移动数据
+	tailMax := b.max - headMax
+	b.tail = append(b.tail, data...)
+	if len(b.tail) > 2*tailMax {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-tailMax:]...)
+	}
+	return len(p), nil
+}
+
+func (b *capBuffer) Truncated() bool {
+	return b.max > 0 && b.total > b.max
+}
+
+func (b *capBuffer) String() string {
+	if !b.Truncated() {
+		return string(b.head) + string(b.tail)
+	}
+	tail := b.tail[len(b.tail)-(b.max-len(b.head)):]
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", b.head, b.total-len(b.head)-len(tail), tail)
+}
+
+// stdout、stderr按行交错合并的日志，每行带时间及来源
+type combinedLog struct {
+	lock sync.Mutex
+	buf  *capBuffer
+}
+
+func (l *combinedLog) writeLine(stream string, line []byte) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	fmt.Fprintf(l.buf, "%s [%s] %s\n", time.Now().Format(COMBINED_TIME_FORMAT), stream, line)
+}
+
+// 一个输出流：完整捕获(受max限制)，并按行写入合并日志(如有)。
+// stdout、stderr在各自的协程中读取并写入，不会因一方的管道写满而阻塞。
+type streamWriter struct {
+	name     string
+	buf      *capBuffer
+	combined *combinedLog
+	partial  []byte // 尚未遇到换行符的内容
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	if w.combined == nil {
+		return len(p), nil
+	}
+
+	data := p
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if len(w.partial) > 0 {
+			w.combined.writeLine(w.name, append(w.partial, data[:i]...))
+			w.partial = w.partial[:0]
+		} else {
+			w.combined.writeLine(w.name, data[:i])
+		}
+		data = data[i+1:]
+	}
+	w.partial = append(w.partial, data...)
+	if len(w.partial) >= COMBINED_MAX_LINE {
+		w.flush()
+	}
+	return len(p), nil
+}
+
+// 将未以换行符结尾的内容作为一行写入合并日志，如命令结束时的最后一行
+func (w *streamWriter) flush() {
+	if w.combined != nil && len(w.partial) > 0 {
+		w.combined.writeLine(w.name, w.partial)
+		w.partial = w.partial[:0]
+	}
+}
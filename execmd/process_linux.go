@@ -0,0 +1,26 @@
+package execmd
+
+import (
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	_P_PID        = 1
+	_WNOWAIT      = 0x1000000
+	_SIGINFO_SIZE = 128
+)
+
+// 等待命令退出但不回收(waitid的WNOWAIT)，之后仍需cmd.Wait()回收。回收前其进程ID、进程组ID不会被复用。
+// 失败时返回false，由调用方直接cmd.Wait()。
+func waitExited(cmd *exec.Cmd) bool {
+	var info [_SIGINFO_SIZE]byte
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, _P_PID, uintptr(cmd.Process.Pid),
+			uintptr(unsafe.Pointer(&info)), syscall.WEXITED|_WNOWAIT, 0, 0)
+		if errno != syscall.EINTR {
+			return errno == 0
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package execmd
+
+import (
+	"os/exec"
+)
+
+// 不支持只等待不回收，命令退出时由cmd.Wait()直接回收，之后终止进程组时其ID可能已被复用(概率极低)
+func waitExited(cmd *exec.Cmd) bool {
+	return false
+}
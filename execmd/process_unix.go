@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package execmd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// 在独立的进程组中执行命令，以便终止时一并终止其子进程
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// 向命令所在的进程组发送SIGTERM
+func terminateGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// 向命令所在的进程组发送SIGKILL
+func killGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
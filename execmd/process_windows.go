@@ -0,0 +1,17 @@
+package execmd
+
+import (
+	"os/exec"
+)
+
+// windows不支持进程组信号，仅终止命令本身
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func killGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,38 @@
+package ginstarter
+
+import (
+	"codeops.didachuxing.com/lordaeron/go-toolbox/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const DEFAULT_CONFIG_ROUTE = "/debug/config"
+
+// 注册一个输出当前生效配置的路由，用于调试。默认不开启，需显式调用。
+// path为空时使用DEFAULT_CONFIG_ROUTE。敏感信息已脱敏。
+//
+// 查询参数format: 'json'(默认)，返回{"config": {...}, "sources": {...}}；'yaml'，返回带来源注释的yaml文本。
+func RegisterConfigRoute(engine *gin.Engine, path string) {
+	if path == "" {
+		path = DEFAULT_CONFIG_ROUTE
+	}
+	engine.GET(path, dumpConfig)
+}
+
+func dumpConfig(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", "json")
+	if format == "json" {
+		Success(ctx, 200, gin.H{
+			"config":  config.ToJSONable(config.Redacted()),
+			"sources": config.Sources(),
+		})
+		return
+	}
+
+	res, err := config.Dump(format)
+	if err != nil {
+		Failed(ctx, 400, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.Data(200, "text/yaml; charset=utf-8", []byte(res))
+}
@@ -23,7 +23,7 @@ func handleJsonBody(data gin.H, result bool, status_code int, ctx *gin.Context)
 
 	if !data["result"].(bool) {
 		slog.Error(fmt.Sprintf("%v\n", data))
-	} else if LogLevel == "DEBUG" {
+	} else if GetLogLevel() == "DEBUG" {
 		datab, _ := json.MarshalIndent(data, "", "  ")
 		datas := string(datab)
 		slog.Debug(datas)
@@ -2,6 +2,7 @@ package ginstarter
 
 import (
 	"strings"
+	"sync"
 
 	"codeops.didachuxing.com/lordaeron/go-toolbox/config"
 	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
@@ -11,8 +12,37 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// 日志级别。配置热加载时会被修改，Init()之后请使用GetLogLevel()读取。
 var LogLevel string
 
+// 保护LogLevel
+var logLevelLock sync.RWMutex
+
+var subscribeOnce sync.Once
+
+func GetLogLevel() string {
+	logLevelLock.RLock()
+	defer logLevelLock.RUnlock()
+	return LogLevel
+}
+
+func setLogLevel(logLevel string) {
+	logLevelLock.Lock()
+	defer logLevelLock.Unlock()
+	LogLevel = strings.ToUpper(logLevel)
+}
+
+// 通用配置的校验规则，在config.Parse()时校验。
+func init() {
+	config.RegisterSchema("ginstarter", config.Schema{
+		Rules: map[string]map[string]interface{}{
+			"log_level":         {"type": "string", "regexp": `(?i)^(debug|info|warning|error|fatal|panic)$`},
+			"listen_addr":       {"type": "string", "not_empty": true},
+			"auth_redirect_url": {"type": "string"},
+		},
+	})
+}
+
 func MakeEngine() *gin.Engine {
 	slog.Debug("Gin engine initiallizing...")
 	if !config.DebugMod {
@@ -37,6 +67,7 @@ func MakeEngine() *gin.Engine {
 	engine.Use(gin.Recovery())
 
 	// Other middlewares.
+	engine.Use(RequestID())
 
 	// 注册默认的healch check方法
 	engine.GET("/health", healthCheck)
@@ -50,12 +81,29 @@ func Init() *gin.Engine {
 	if err != nil {
 		panic(fmt.Sprintf("ERROR: Fail To load config file. %s", err.Error()))
 	}
+	// 处理`-dump-config`等命令行参数
+	config.HandleFlags()
 	// 初始化一个全局SimpleLog
-	LogLevel = strings.ToUpper(config.LogLevel)
-	slog.SlogInit(config.LogLevel, "GinStarter.Init(): ")
+	setLogLevel(config.GetLogLevel())
+	slog.SlogInit(config.GetLogLevel(), "GinStarter.Init(): ")
 	defer slog.RemovePrefix()
 	slog.Info("Config Data loaded successfully.")
 
+	// 配置热加载时，同步修改日志级别。只注册一次。
+	subscribeOnce.Do(func() {
+		config.Subscribe("log_level", changeLogLevel)
+	})
+
 	// 初始化gin引擎
 	return MakeEngine()
 }
+
+func changeLogLevel(key string, oldVal, newVal interface{}) {
+	logLevel := config.GetLogLevel()
+	setLogLevel(logLevel)
+	if err := slog.SetLevel(logLevel); err != nil {
+		slog.Error(fmt.Sprintf("GinStarter: failed to change log level. %s", err.Error()))
+		return
+	}
+	slog.Info(fmt.Sprintf("GinStarter: log level changed from '%v' to '%s'.", oldVal, logLevel))
+}
@@ -0,0 +1,39 @@
+package ginstarter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/config"
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+)
+
+func TestLogLevelReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("log_level: info\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldFiles := config.ConfigFiles
+	config.ConfigFiles = []string{path}
+	t.Cleanup(func() {
+		config.ConfigFiles = oldFiles
+		config.Parse()
+		slog.SetLevel(slog.DEFAULT_TRIGGER_LEVEL)
+	})
+
+	Init()
+	if GetLogLevel() != "INFO" || slog.Enabled("DEBUG") {
+		t.Fatalf("expect log level INFO, got %s", GetLogLevel())
+	}
+
+	if err := os.WriteFile(path, []byte("log_level: debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if GetLogLevel() != "DEBUG" || !slog.Enabled("DEBUG") {
+		t.Errorf("expect log level DEBUG after reload, got %s", GetLogLevel())
+	}
+}
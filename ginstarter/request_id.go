@@ -0,0 +1,31 @@
+package ginstarter
+
+import (
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求ID的HTTP头，请求中提供时沿用，否则生成一个新的
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// 请求ID在gin.Context中的key。以db.WithContext(ctx)执行的SQL语句，dbstarter会在日志中带上请求ID。
+const CTX_REQUEST_ID_KEY = tools.CTX_REQUEST_ID_KEY
+
+// 为每个请求设置请求ID，并在响应头中返回
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(REQUEST_ID_HEADER)
+		if id == "" {
+			id = tools.GenUUID(32, true)
+		}
+		ctx.Set(CTX_REQUEST_ID_KEY, id)
+		ctx.Header(REQUEST_ID_HEADER, id)
+		ctx.Next()
+	}
+}
+
+// 获取当前请求的ID，未使用RequestID中间件时返回空字符串
+func GetRequestID(ctx *gin.Context) string {
+	return ctx.GetString(CTX_REQUEST_ID_KEY)
+}
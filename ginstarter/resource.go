@@ -0,0 +1,303 @@
+package ginstarter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/dbstarter"
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+	"codeops.didachuxing.com/lordaeron/go-toolbox/tools"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 500响应中的错误信息
+const INTERNAL_ERROR_MSG = "internal server error"
+
+// 当前用户在gin.Context中的key，由认证中间件设置，RegisterResource用于填写created_by、updated_by
+const CTX_USER_KEY = "user"
+
+// 判断当前用户是否为管理员，只有管理员可以使用include_deleted查询已软删除的记录。为nil表示没有管理员。
+var IsAdmin func(ctx *gin.Context) bool
+
+// 获取认证中间件设置的当前用户，没有时返回nil
+func CurrentUser(ctx *gin.Context) interface{} {
+	user, _ := ctx.Get(CTX_USER_KEY)
+	return user
+}
+
+// RegisterResource所用的数据校验规则，均为可选。
+type ResourceValidators struct {
+	List   *DataValidator // 校验查询参数，查询参数均为字符串，需转换类型的过滤字段请使用auto_convert
+	Create *DataValidator // 校验新增数据，为空时使用Serializor.Validator
+	Update *DataValidator // 校验更新数据，为空时使用Create
+}
+
+// 注册一个REST资源的增删改查路由，以Serializor操作table：
+//
+//	GET    <path>        列表。查询参数含cursor时使用游标分页(CursorQuery)，含group_by或agg时为聚合查询(AggregateQuery)，否则使用ListQuery
+//	GET    <path>/export 导出列表的全部结果，查询参数format: csv(默认), ndjson, xlsx
+//	GET    <path>/:id    详情
+//	POST   <path>        新增
+//	PUT    <path>/:id    全量更新
+//	PATCH  <path>/:id    部分更新
+//	DELETE <path>/:id    删除，Serializor.SoftDelete为true时为软删除
+//
+// 写操作的用户取自CurrentUser(ctx)。查询参数或数据不合法时返回400，非管理员使用include_deleted时返回403，记录不存在时返回404。
+// 其他错误(如数据库错误)只记录在日志中，响应为500及INTERNAL_ERROR_MSG，以免泄露表结构等信息。
+func RegisterResource(engine gin.IRouter, path string, table string, slz *dbstarter.Serializor, validators ResourceValidators) {
+	res := &resource{table: table, slz: slz, validators: validators}
+	itemPath := path + "/:id"
+	engine.GET(path, res.list)
+	engine.GET(path+"/export", res.export)
+	engine.GET(itemPath, res.detail)
+	engine.POST(path, res.create)
+	engine.PUT(itemPath, res.update)
+	engine.PATCH(itemPath, res.partialUpdate)
+	engine.DELETE(itemPath, res.delete)
+}
+
+type resource struct {
+	table      string
+	slz        *dbstarter.Serializor
+	validators ResourceValidators
+}
+
+// 将URL查询参数解析为ListQuery、CursorQuery所需的数据。
+// 同名参数出现多次时为list(如`id__in=1&id__in=2`)，page_index、page_size转换为int，pagination、skip_count、include_deleted转换为bool。
+func ParseQueryData(ctx *gin.Context) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	for key, vals := range ctx.Request.URL.Query() {
+		if len(vals) == 1 {
+			data[key] = vals[0]
+			continue
+		}
+		list := make([]interface{}, 0, len(vals))
+		for _, val := range vals {
+			list = append(list, val)
+		}
+		data[key] = list
+	}
+
+	for _, key := range []string{"page_index", "page_size"} {
+		if val, ok := data[key].(string); ok {
+			i, err := strconv.Atoi(val)
+			if err != nil || i <= 0 {
+				return nil, fmt.Errorf("query param '%s' must be a positive integer", key)
+			}
+			data[key] = i
+		}
+	}
+	for _, key := range []string{"pagination", "skip_count", "include_deleted"} {
+		if val, ok := data[key].(string); ok {
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, fmt.Errorf("query param '%s' must be a bool", key)
+			}
+			data[key] = b
+		}
+	}
+	return data, nil
+}
+
+// 解析json格式的请求数据，数字解析为json.Number，以便DataValidator校验int类型
+func parseJSONBody(ctx *gin.Context) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	decoder := json.NewDecoder(ctx.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid json body. %s", err.Error())
+	}
+	return data, nil
+}
+
+// 按错误类型返回对应的状态码
+func failedWithError(ctx *gin.Context, err error) {
+	var queryErr *dbstarter.QueryError
+	switch {
+	case errors.As(err, &queryErr):
+		Failed(ctx, 400, gin.H{"msg": err.Error()})
+	case errors.Is(err, dbstarter.ErrNotFound):
+		Failed(ctx, 404, gin.H{"msg": err.Error()})
+	default:
+		slog.Error(fmt.Sprintf("ginstarter: %s %s failed, request id '%s'. %s", ctx.Request.Method, ctx.Request.URL.Path, GetRequestID(ctx), err.Error()))
+		Failed(ctx, 500, gin.H{"msg": INTERNAL_ERROR_MSG})
+	}
+}
+
+// 解析并校验列表查询参数，失败时已返回错误响应
+func (res *resource) listQueryData(ctx *gin.Context) (map[string]interface{}, bool) {
+	queryData, err := ParseQueryData(ctx)
+	if err != nil {
+		Failed(ctx, 400, gin.H{"msg": err.Error()})
+		return nil, false
+	}
+	if includeDeleted, _ := queryData["include_deleted"].(bool); includeDeleted && (IsAdmin == nil || !IsAdmin(ctx)) {
+		Failed(ctx, 403, gin.H{"msg": "only admin can query deleted records"})
+		return nil, false
+	}
+	if res.validators.List != nil {
+		if err := res.validators.List.DataValidate(queryData); err != nil {
+			Failed(ctx, 400, gin.H{"msg": err.Error()})
+			return nil, false
+		}
+	}
+	return queryData, true
+}
+
+func (res *resource) list(ctx *gin.Context) {
+	queryData, ok := res.listQueryData(ctx)
+	if !ok {
+		return
+	}
+
+	if _, ok := queryData["cursor"]; ok {
+		page, err := res.serializor(ctx, nil).CursorQuery(res.table, queryData)
+		if err != nil {
+			failedWithError(ctx, err)
+			return
+		}
+		Success(ctx, 200, gin.H{
+			"results":     page.Results,
+			"total_size":  page.TotalSize,
+			"next_cursor": page.NextCursor,
+			"prev_cursor": page.PrevCursor,
+		})
+		return
+	}
+
+	slz := res.serializor(ctx, nil)
+	query := slz.ListQuery
+	if _, ok := queryData["group_by"]; ok {
+		query = slz.AggregateQuery
+	} else if _, ok := queryData["agg"]; ok {
+		query = slz.AggregateQuery
+	}
+	totalSize, dbtx, err := query(res.table, queryData)
+	if err != nil {
+		failedWithError(ctx, err)
+		return
+	}
+	results := []map[string]interface{}{}
+	if err := dbtx.Find(&results).Error; err != nil {
+		failedWithError(ctx, err)
+		return
+	}
+	Success(ctx, 200, gin.H{"results": results, "total_size": totalSize})
+}
+
+func (res *resource) export(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", dbstarter.EXPORT_CSV)
+	if !tools.IsStrInSlice(format, dbstarter.GetExportFormats()) {
+		Failed(ctx, 400, gin.H{"msg": fmt.Sprintf("unsupported export format '%s', must be one of %v", format, dbstarter.GetExportFormats())})
+		return
+	}
+	queryData, ok := res.listQueryData(ctx)
+	if !ok {
+		return
+	}
+	delete(queryData, "format")
+	Export(ctx, res.table, res.serializor(ctx, nil), queryData, format)
+}
+
+// 以附件形式，流式导出Serializor.ListQuery的全部结果，文件名为<table>.<format>。
+// 开始输出后发生的错误只能记录日志，客户端将收到不完整的文件。
+func Export(ctx *gin.Context, table string, slz *dbstarter.Serializor, queryData map[string]interface{}, format string) {
+	ctx.Header("Content-Type", dbstarter.ExportContentType(format))
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, table, format))
+	ctx.Status(200)
+	if err := slz.Export(ctx.Writer, table, queryData, format); err != nil {
+		if ctx.Writer.Written() {
+			slog.Error(fmt.Sprintf("ginstarter: failed to export '%s'. %s", table, err.Error()))
+			return
+		}
+		ctx.Writer.Header().Del("Content-Type")
+		ctx.Writer.Header().Del("Content-Disposition")
+		failedWithError(ctx, err)
+	}
+}
+
+func (res *resource) detail(ctx *gin.Context) {
+	id, err := ParseID(ctx)
+	if err != nil {
+		Failed(ctx, 400, gin.H{"msg": err.Error()})
+		return
+	}
+	data, err := res.serializor(ctx, nil).DetailQuery(res.table, id)
+	if err != nil {
+		failedWithError(ctx, err)
+		return
+	}
+	Success(ctx, 200, gin.H{"data": data})
+}
+
+// 当前请求使用的Serializor拷贝，设置当前用户、Ctx，及指定的校验规则(不为nil时)
+func (res *resource) serializor(ctx *gin.Context, v *DataValidator) *dbstarter.Serializor {
+	slz := *res.slz
+	slz.User = CurrentUser(ctx)
+	slz.Ctx = ctx
+	if v != nil {
+		slz.Validator = v
+	}
+	return &slz
+}
+
+func (res *resource) create(ctx *gin.Context) {
+	body, err := parseJSONBody(ctx)
+	if err != nil {
+		Failed(ctx, 400, gin.H{"msg": err.Error()})
+		return
+	}
+	data, err := res.serializor(ctx, res.validators.Create).Create(res.table, body)
+	if err != nil {
+		failedWithError(ctx, err)
+		return
+	}
+	Success(ctx, 201, gin.H{"data": data})
+}
+
+func (res *resource) update(ctx *gin.Context) {
+	res.doUpdate(ctx, false)
+}
+
+func (res *resource) partialUpdate(ctx *gin.Context) {
+	res.doUpdate(ctx, true)
+}
+
+func (res *resource) doUpdate(ctx *gin.Context, partial bool) {
+	id, err := ParseID(ctx)
+	if err != nil {
+		Failed(ctx, 400, gin.H{"msg": err.Error()})
+		return
+	}
+	body, err := parseJSONBody(ctx)
+	if err != nil {
+		Failed(ctx, 400, gin.H{"msg": err.Error()})
+		return
+	}
+	v := res.validators.Update
+	if v == nil {
+		v = res.validators.Create
+	}
+	data, err := res.serializor(ctx, v).Update(res.table, id, body, partial)
+	if err != nil {
+		failedWithError(ctx, err)
+		return
+	}
+	Success(ctx, 200, gin.H{"data": data})
+}
+
+func (res *resource) delete(ctx *gin.Context) {
+	id, err := ParseID(ctx)
+	if err != nil {
+		Failed(ctx, 400, gin.H{"msg": err.Error()})
+		return
+	}
+	if err := res.serializor(ctx, nil).Delete(res.table, id, res.slz.SoftDelete); err != nil {
+		failedWithError(ctx, err)
+		return
+	}
+	Success(ctx, 200, gin.H{"msg": "OK"})
+}
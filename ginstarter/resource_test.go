@@ -0,0 +1,78 @@
+package ginstarter
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/dbstarter"
+	_ "codeops.didachuxing.com/lordaeron/go-toolbox/dbstarter/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestEngine(t *testing.T) *gin.Engine {
+	t.Helper()
+	db, err := dbstarter.Open(dbstarter.ConnConfig{Driver: dbstarter.DRIVER_SQLITE, Name: dbstarter.SQLITE_MEMORY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)").Error; err != nil {
+		t.Fatal(err)
+	}
+	dbstarter.Register(t.Name(), db)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RequestID())
+	slz := &dbstarter.Serializor{Conn: t.Name(), FilterFields: []string{"name"}, WritableFields: []string{"name"}}
+	RegisterResource(engine, "/users", "users", slz, ResourceValidators{})
+	RegisterResource(engine, "/missing", "missing_table", slz, ResourceValidators{})
+	return engine
+}
+
+func TestResourceErrors(t *testing.T) {
+	engine := newTestEngine(t)
+
+	cases := []struct {
+		method, path, body string
+		code               int
+		msg                string
+	}{
+		{"POST", "/users", `{"name": "tom"}`, 201, ""},
+		{"GET", "/users/1", "", 200, ""},
+		{"GET", "/users?age=1", "", 400, "age"},
+		{"POST", "/users", `{"age": 1}`, 400, "age"},
+		{"GET", "/users/404", "", 404, "not found"},
+		{"DELETE", "/users/404", "", 404, "not found"},
+		{"GET", "/missing", "", 500, INTERNAL_ERROR_MSG},
+		{"GET", "/missing/1", "", 500, INTERNAL_ERROR_MSG},
+		{"POST", "/missing", `{"name": "tom"}`, 500, INTERNAL_ERROR_MSG},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("%s %s: expect status %d, got %d. %s", c.method, c.path, c.code, w.Code, w.Body.String())
+			continue
+		}
+		if c.msg == "" {
+			continue
+		}
+		body := map[string]interface{}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		msg, _ := body["msg"].(string)
+		if !strings.Contains(msg, c.msg) {
+			t.Errorf("%s %s: expect msg containing %q, got %q", c.method, c.path, c.msg, msg)
+		}
+		// 数据库错误不应返回给客户端
+		if c.code == 500 && msg != INTERNAL_ERROR_MSG {
+			t.Errorf("%s %s: unexpected msg %q", c.method, c.path, msg)
+		}
+	}
+}
@@ -20,56 +20,63 @@ var (
 	Pool *nebula.ConnectionPool
 )
 
+// nebula_info配置的校验规则，在config.Parse()时校验。
+func init() {
+	config.RegisterSchema("nebula_client", config.Schema{
+		Section: "nebula_info",
+		Rules: map[string]map[string]interface{}{
+			"host":     {"type": "string", "required": true, "not_empty": true},
+			"port":     {"type": "int", "required": true, "min": 1, "max": 65535},
+			"user":     {"type": "string", "required": true, "not_empty": true},
+			"password": {"type": "string"},
+			"space":    {"type": "string", "required": true, "not_empty": true},
+		},
+	})
+}
+
+// 对应config中的`nebula_info`配置
+type nebulaConfig struct {
+	Host     string `config:"host,required"` // 多个host以','分隔
+	Port     int    `config:"port,required"`
+	User     string `config:"user,required"`
+	Password string `config:"password"`
+	Space    string `config:"space,required"`
+}
+
 // 解析原始配置数据
 func getNebulaConfig() error {
-	// nebula配置是个json字典，对应实际数据类型应该是个`map[interface{}]interface{}`
-	nebulaConfigRaw, _ := config.RawData["nebula_info"].(map[interface{}]interface{})
-	if nebulaConfigRaw == nil {
+	if _, ok := config.Get("nebula_info"); !ok {
 		return errors.New(`cannot get 'nebula_info' from config`)
 	}
+	conf := nebulaConfig{}
+	if err := config.Bind("nebula_info", &conf); err != nil {
+		return err
+	}
 
-	// 依次对key，val做interface类型判断。填充配置信息全局变量。
-	for k, v := range nebulaConfigRaw {
-		switch key := k.(string); key {
-		case "host":
-			hostStr, _ := v.(string)
-			if hostStr == "" {
-				return errors.New(`invalid nebula host config`)
-			}
-			hosts := strings.Split(hostStr, ",")
-			for _, val := range hosts {
-				host := strings.TrimSpace(val)
-				if host == "" {
-					return errors.New(`invalid nebula host config, empty string found`)
-				}
-				NebulaHosts = append(NebulaHosts, host)
-			}
-			if len(NebulaHosts) <= 0 {
-				return errors.New(`invalid nebula host config, no effective nebula host configured`)
-			}
-
-		case "port":
-			NebulaPort, _ = v.(int)
-			if NebulaPort <= 0 {
-				return errors.New(`invalid nebula port config`)
-			}
-		case "user":
-			val, _ := v.(string)
-			NebulaUser = strings.TrimSpace(val)
-			if NebulaUser == "" {
-				return errors.New(`invalid nebula user config`)
-			}
-		case "password":
-			NebulaPassword, _ = v.(string)
-		case "space":
-			val, _ := v.(string)
-			NebulaSpace = strings.TrimSpace(val)
-			if NebulaSpace == "" {
-				return errors.New(`invalid nebula space config`)
-			}
-		default:
-			return fmt.Errorf(`unrecorginzed field '%s' in 'nebula_info' config`, key)
+	// 填充配置信息全局变量。
+	NebulaHosts = nil
+	for _, val := range strings.Split(conf.Host, ",") {
+		host := strings.TrimSpace(val)
+		if host == "" {
+			return errors.New(`invalid nebula host config, empty string found`)
 		}
+		NebulaHosts = append(NebulaHosts, host)
+	}
+	if len(NebulaHosts) <= 0 {
+		return errors.New(`invalid nebula host config, no effective nebula host configured`)
+	}
+	NebulaPort = conf.Port
+	if NebulaPort <= 0 {
+		return errors.New(`invalid nebula port config`)
+	}
+	NebulaUser = strings.TrimSpace(conf.User)
+	if NebulaUser == "" {
+		return errors.New(`invalid nebula user config`)
+	}
+	NebulaPassword = conf.Password
+	NebulaSpace = strings.TrimSpace(conf.Space)
+	if NebulaSpace == "" {
+		return errors.New(`invalid nebula space config`)
 	}
 
 	return nil
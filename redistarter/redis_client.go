@@ -2,6 +2,7 @@ package redistarter
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -12,6 +13,61 @@ const DEFAULT_IDLE_TIMEOUT = 300 * time.Second
 
 var Pool *redis.Pool
 
+// 保护Pool，Resize()时会整体替换连接池。
+var poolLock sync.RWMutex
+
+// 当前连接池中借出的连接，Resize()时等待其全部归还后再关闭旧连接池。
+var borrowers = &sync.WaitGroup{}
+
+// 从当前连接池获取一个连接，连接池未初始化时返回nil。使用完毕后须调用Close()归还。
+func getConn() redis.Conn {
+	poolLock.RLock()
+	defer poolLock.RUnlock()
+	if Pool == nil {
+		return nil
+	}
+	borrowers.Add(1)
+	return &borrowedConn{Conn: Pool.Get(), done: borrowers.Done}
+}
+
+// 归还时通知Resize()的连接
+type borrowedConn struct {
+	redis.Conn
+	done func()
+	once sync.Once
+}
+
+func (c *borrowedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.done)
+	return err
+}
+
+func newPool(address, password string, idleConnections int, idleTimeout time.Duration) *redis.Pool {
+	// 处理参数默认值
+	connections := idleConnections
+	if connections <= 0 {
+		connections = DEFAULT_IDLE_SIZE
+	}
+
+	timeout := idleTimeout
+	if timeout <= 0 {
+		timeout = DEFAULT_IDLE_TIMEOUT
+	}
+
+	return &redis.Pool{
+		MaxIdle:     connections,
+		IdleTimeout: timeout,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+		Dial: func() (redis.Conn, error) {
+			return dial("tcp", address, password)
+		},
+	}
+}
+
 func dial(network, address, password string) (redis.Conn, error) {
 	c, err := redis.Dial(network, address)
 	if err != nil {
@@ -31,40 +87,37 @@ func dial(network, address, password string) (redis.Conn, error) {
 //     idleConnections: 0表示使用默认值'10'
 //     idleTimeout: 0表示使用默认值300s
 func Init(address, password string, idleConnections int, idleTimeout time.Duration) {
-	// 处理参数默认值
-	connections := idleConnections
-	if connections <= 0 {
-		connections = DEFAULT_IDLE_SIZE
+	// 初始化连接池
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	if Pool == nil {
+		Pool = newPool(address, password, idleConnections, idleTimeout)
 	}
+}
 
-	timeout := idleTimeout
-	if timeout <= 0 {
-		timeout = DEFAULT_IDLE_TIMEOUT
-	}
+// 用途：用新的参数重建全局redis连接池，如配置热加载时。
+// 之后的请求使用新的连接池；旧连接池在已借出的连接全部归还后关闭，进行中的请求不受影响。
+func Resize(address, password string, idleConnections int, idleTimeout time.Duration) {
+	poolLock.Lock()
+	oldPool, oldBorrowers := Pool, borrowers
+	Pool = newPool(address, password, idleConnections, idleTimeout)
+	borrowers = &sync.WaitGroup{}
+	poolLock.Unlock()
 
-	// 初始化连接池
-	if Pool == nil {
-		Pool = &redis.Pool{
-			MaxIdle:     connections,
-			IdleTimeout: timeout,
-			TestOnBorrow: func(c redis.Conn, t time.Time) error {
-				_, err := c.Do("PING")
-				return err
-			},
-			Dial: func() (redis.Conn, error) {
-				return dial("tcp", address, password)
-			},
-		}
+	if oldPool != nil {
+		go func() {
+			oldBorrowers.Wait()
+			oldPool.Close()
+		}()
 	}
 }
 
 // 便捷方法: 根据指定的key，从redis中获取一个string类型的value
 func GetStrVal(key string) (string, error) {
-	if Pool == nil {
+	conn := getConn()
+	if conn == nil {
 		return "", errors.New("GetStrval(): redis client pool is not initiallized")
 	}
-
-	conn := Pool.Get()
 	defer conn.Close()
 	if err := conn.Err(); err != nil {
 		return "", err
@@ -85,11 +138,10 @@ func GetStrVal(key string) (string, error) {
 
 // 便捷方法: 根据指定的key，从redis中获取一个string类型的value
 func SetStrVal(key string, val string) error {
-	if Pool == nil {
+	conn := getConn()
+	if conn == nil {
 		return errors.New("GetStrval(): redis client pool is not initiallized")
 	}
-
-	conn := Pool.Get()
 	defer conn.Close()
 	if err := conn.Err(); err != nil {
 		return err
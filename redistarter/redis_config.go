@@ -0,0 +1,59 @@
+package redistarter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/config"
+	slog "codeops.didachuxing.com/lordaeron/go-toolbox/simplelog"
+)
+
+// 对应config中的`redis_info`配置
+type redisConfig struct {
+	Address         string        `config:"address,required"`
+	Password        string        `config:"password"`
+	IdleConnections int           `config:"idle_connections"`
+	IdleTimeout     time.Duration `config:"idle_timeout"`
+}
+
+// redis_info配置的校验规则，在config.Parse()时校验。
+func init() {
+	config.RegisterSchema("redistarter", config.Schema{
+		Section: "redis_info",
+		Rules: map[string]map[string]interface{}{
+			"address":          {"type": "string", "required": true, "not_empty": true},
+			"password":         {"type": "string"},
+			"idle_connections": {"type": "int", "min": 0},
+			"idle_timeout":     {"type": "duration", "min": 0}, // "30s"格式，或秒数
+		},
+	})
+}
+
+// 用途：根据config中的`redis_info`配置，初始化一个全局redis连接池。
+// 配置热加载时，将按新的配置重建连接池。
+func InitFromConfig() error {
+	conf := redisConfig{}
+	if err := config.Bind("redis_info", &conf); err != nil {
+		return err
+	}
+	Init(conf.Address, conf.Password, conf.IdleConnections, conf.IdleTimeout)
+
+	subscribeOnce.Do(func() {
+		config.Subscribe("redis_info", resizePool)
+	})
+	return nil
+}
+
+// 热加载的订阅只注册一次，重复调用InitFromConfig()时不会重复重建连接池
+var subscribeOnce sync.Once
+
+func resizePool(key string, oldVal, newVal interface{}) {
+	conf := redisConfig{}
+	if err := config.Bind("redis_info", &conf); err != nil {
+		slog.Error(fmt.Sprintf("redistarter: failed to resize redis pool. %s", err.Error()))
+		return
+	}
+	Resize(conf.Address, conf.Password, conf.IdleConnections, conf.IdleTimeout)
+	slog.Info(fmt.Sprintf("redistarter: redis pool rebuilt, idle_connections: %d, idle_timeout: %s.", conf.IdleConnections, conf.IdleTimeout))
+}
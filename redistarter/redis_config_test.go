@@ -0,0 +1,78 @@
+package redistarter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"codeops.didachuxing.com/lordaeron/go-toolbox/config"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// redigo未导出连接池已关闭的错误
+func isClosed(pool *redis.Pool) bool {
+	c := pool.Get()
+	defer c.Close()
+	return c.Err() != nil && c.Err().Error() == "redigo: get on closed pool"
+}
+
+func TestResizeOnReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "redis_info:\n  address: 127.0.0.1:1\n")
+	oldFiles := config.ConfigFiles
+	config.ConfigFiles = []string{path}
+	t.Cleanup(func() {
+		config.ConfigFiles = oldFiles
+		config.Parse()
+	})
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitFromConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	poolLock.RLock()
+	oldPool := Pool
+	poolLock.RUnlock()
+	conn := getConn()
+
+	writeConfig(t, path, "redis_info:\n  address: 127.0.0.1:2\n  idle_connections: 3\n")
+	if err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	poolLock.RLock()
+	current := Pool
+	poolLock.RUnlock()
+	if current == oldPool || current.MaxIdle != 3 {
+		t.Fatalf("expect the pool to be rebuilt, got %+v", current)
+	}
+
+	// 有借出的连接时，旧连接池不会被关闭
+	time.Sleep(50 * time.Millisecond)
+	if isClosed(oldPool) {
+		t.Error("old pool should not be closed while a connection is borrowed")
+	}
+
+	// 归还后关闭
+	conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if isClosed(oldPool) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("old pool should be closed after the connection is returned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
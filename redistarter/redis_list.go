@@ -13,11 +13,10 @@ func (emp EmptyListError) Error() string {
 }
 
 func Rpush(listkey string, item string) error {
-	if Pool == nil {
+	conn := getConn()
+	if conn == nil {
 		return errors.New("GetStrval(): redis client pool is not initiallized")
 	}
-
-	conn := Pool.Get()
 	defer conn.Close()
 	if err := conn.Err(); err != nil {
 		return err
@@ -27,11 +26,10 @@ func Rpush(listkey string, item string) error {
 }
 
 func Lpush(listkey string, item string) error {
-	if Pool == nil {
+	conn := getConn()
+	if conn == nil {
 		return errors.New("GetStrval(): redis client pool is not initiallized")
 	}
-
-	conn := Pool.Get()
 	defer conn.Close()
 	if err := conn.Err(); err != nil {
 		return err
@@ -41,11 +39,10 @@ func Lpush(listkey string, item string) error {
 }
 
 func Lpop(listkey string) (string, error) {
-	if Pool == nil {
+	conn := getConn()
+	if conn == nil {
 		return "", errors.New("GetStrval(): redis client pool is not initiallized")
 	}
-
-	conn := Pool.Get()
 	defer conn.Close()
 	if err := conn.Err(); err != nil {
 		return "", err
@@ -65,11 +62,10 @@ func Lpop(listkey string) (string, error) {
 }
 
 func Rpop(listkey string) (string, error) {
-	if Pool == nil {
+	conn := getConn()
+	if conn == nil {
 		return "", errors.New("GetStrval(): redis client pool is not initiallized")
 	}
-
-	conn := Pool.Get()
 	defer conn.Close()
 	if err := conn.Err(); err != nil {
 		return "", err
@@ -89,11 +85,10 @@ func Rpop(listkey string) (string, error) {
 }
 
 func Llen(listkey string) (int64, error) {
-	if Pool == nil {
+	conn := getConn()
+	if conn == nil {
 		return 0, errors.New("GetStrval(): redis client pool is not initiallized")
 	}
-
-	conn := Pool.Get()
 	defer conn.Close()
 	if err := conn.Err(); err != nil {
 		return 0, err
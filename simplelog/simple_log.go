@@ -17,13 +17,14 @@ package simplelog
 */
 
 import (
+	"fmt"
 	"log"
 	"strings"
+	"sync/atomic"
 )
 
 type SimpleLog struct {
-	triggerLevel    string
-	triggerLevelNum int
+	triggerLevelNum int32 // 运行时可通过SetLevel()修改，需原子读写
 
 	MessagePrefix string
 }
@@ -44,9 +45,14 @@ var LogLevel string
 
 // --------------------------------- logge方法 ---------------------------------
 
+// 该级别的日志是否会被输出。构造日志内容的开销较大时，可先判断，避免无用的计算。
+func (logger *SimpleLog) Enabled(level string) bool {
+	return int32(level_map[strings.ToUpper(level)]) >= atomic.LoadInt32(&logger.triggerLevelNum)
+}
+
 // The dispatcher.
 func (logger *SimpleLog) dispatch(level string, message string) {
-	if level_map[level] >= logger.triggerLevelNum {
+	if logger.Enabled(level) {
 		log.Printf("[%s] %s%s", level, logger.MessagePrefix, message)
 	}
 }
@@ -77,7 +83,7 @@ func (logger *SimpleLog) Fatal(message string) {
 }
 
 func (logger *SimpleLog) Panic(message string) {
-	if level_map["PANIC"] >= logger.triggerLevelNum {
+	if int32(level_map["PANIC"]) >= atomic.LoadInt32(&logger.triggerLevelNum) {
 		log.Panicf("[PANIC] %s%s", logger.MessagePrefix, message)
 	}
 }
@@ -86,12 +92,22 @@ func (logger *SimpleLog) RemovePrefix() {
 	logger.MessagePrefix = ""
 }
 
+// 修改日志触发级别，可在运行时调用，如配置热加载时。
+func (logger *SimpleLog) SetLevel(level string) error {
+	levelUpper := strings.ToUpper(level)
+	levelNum, ok := level_map[levelUpper]
+	if !ok {
+		return fmt.Errorf("illegal trigger level '%s' for `SimpleLog`", level)
+	}
+	atomic.StoreInt32(&logger.triggerLevelNum, int32(levelNum))
+	return nil
+}
+
 // --------------------------------- logger初始化 ---------------------------------
 
 func makeLogger(args ...string) *SimpleLog {
 	logger := SimpleLog{
-		triggerLevel:    DEFAULT_TRIGGER_LEVEL,
-		triggerLevelNum: level_map[DEFAULT_TRIGGER_LEVEL],
+		triggerLevelNum: int32(level_map[DEFAULT_TRIGGER_LEVEL]),
 	}
 
 	// 设置logLevel.
@@ -105,8 +121,7 @@ func makeLogger(args ...string) *SimpleLog {
 		if !ok {
 			panic("Illegal trigger level setting for `SimpleLog`!")
 		}
-		logger.triggerLevel = levelUpper
-		logger.triggerLevelNum = levelNum
+		logger.triggerLevelNum = int32(levelNum)
 	}
 
 	// 设置MessagePrefix.
@@ -170,6 +185,13 @@ func Panic(message string) {
 	dispatch("PANIC", message)
 }
 
+func Enabled(level string) bool {
+	if Slog == nil {
+		SlogInit()
+	}
+	return Slog.Enabled(level)
+}
+
 func SetPrefix(prefix string) {
 	if Slog == nil {
 		SlogInit()
@@ -183,3 +205,10 @@ func RemovePrefix() {
 	}
 	Slog.MessagePrefix = ""
 }
+
+func SetLevel(level string) error {
+	if Slog == nil {
+		SlogInit()
+	}
+	return Slog.SetLevel(level)
+}
@@ -0,0 +1,5 @@
+package tools
+
+// 请求ID在context中的key，ginstarter设置、dbstarter在SQL日志中读取。
+// 使用string类型，是因为gin.Context.Value()只查找string类型的key。
+const CTX_REQUEST_ID_KEY = "request_id"